@@ -3,12 +3,14 @@ package plugin
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,9 +19,18 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
+// pluginDataDir is where the plugin keeps its jobs, configuration and state
+const pluginDataDir = "/var/lib/grafana/plugin-data/progressio-grafanareporter-app"
+
+// pluginID is the ID of the plugin in Grafana, which its resource URLs contain
+const pluginID = "progressio-grafanareporter-app"
+
 var (
 	// BuildVersion is the version of the plugin, set at build time
 	BuildVersion = "dev"
@@ -46,6 +57,28 @@ type Job struct {
 	Subject      string            `json:"subject"`
 	Body         string            `json:"body"`
 	Variables    map[string][]string `json:"variables,omitempty"` // Dashboard variables (supports multiple values per key)
+	Provisioned  bool              `json:"provisioned,omitempty"` // Managed by provisioning files, read-only through the API
+	Owner        string            `json:"owner,omitempty"`       // Login of the Grafana user who created the job
+	Subscribers  []string          `json:"subscribers,omitempty"` // Emails of users who subscribed themselves, in addition to Recipients
+	TimeoutSeconds int             `json:"timeoutSeconds,omitempty"` // Total time allowed for a run (render and delivery), default 5 minutes
+	ResumeInterrupted bool         `json:"resumeInterrupted,omitempty"` // Re-run the job on startup if a run was interrupted by a restart
+	MisfirePolicy string           `json:"misfirePolicy,omitempty"`  // What to do with runs missed while the plugin was down: skip (default), once or all
+	MisfireMaxRuns int             `json:"misfireMaxRuns,omitempty"` // Maximum catch-up runs for the "all" policy, default 10
+	StartAt      *time.Time        `json:"startAt,omitempty"`  // Occurrences before this time are skipped
+	EndAt        *time.Time        `json:"endAt,omitempty"`    // Occurrences after this time are skipped, and the job is then disabled
+	RunAt        *time.Time        `json:"runAt,omitempty"`    // One-shot mode: run once at this time instead of following Cron
+	Disabled     bool              `json:"disabled,omitempty"` // Disabled jobs are not scheduled
+	Calendar     string            `json:"calendar,omitempty"`       // ID of the business calendar restricting the days the job runs on
+	CalendarPolicy string          `json:"calendarPolicy,omitempty"` // What to do with occurrences on excluded days: skip (default) or nextBusinessDay
+	TimeRange    string            `json:"timeRange,omitempty"` // Calendar-aligned range such as previousMonth, used instead of From/To
+	Timezone     string            `json:"timezone,omitempty"`  // IANA time zone relative time ranges are computed in, default: UTC
+	CompareOffset string           `json:"compareOffset,omitempty"` // Also render the period this far back (e.g. 1w) and send both side by side
+	RenderOptions *RenderOptions   `json:"renderOptions,omitempty"` // Theme, kiosk mode and other renderer settings
+	PDF          *PDFOptions       `json:"pdf,omitempty"` // Page layout of PDF reports
+	Image        *ImageOptions     `json:"image,omitempty"` // Cropping, scaling, format and watermark of rendered images
+	Delivery     string            `json:"delivery,omitempty"` // How reports reach recipients: attachment (default) or link
+	Links        *LinkOptions      `json:"links,omitempty"`    // Expiry and single use of the download links sent to recipients
+	Archive      *ArchiveOptions   `json:"archive,omitempty"`  // Archiving of every run's reports and retention rules
 }
 
 // UnmarshalJSON custom unmarshaler to handle backward compatibility for Variables field
@@ -106,6 +139,9 @@ type Config struct {
 	SMTPUser      string `json:"smtpUser"`
 	SMTPPassword  string `json:"smtpPassword"`
 	SMTPFrom      string `json:"smtpFrom"`
+	FiscalYearStartMonth int `json:"fiscalYearStartMonth,omitempty"` // First month of the fiscal year (1-12), default January
+	MaxEmailBytes int64 `json:"maxEmailBytes,omitempty"` // Largest email to send, in addition to the limit advertised by the SMTP server
+	DownloadLinkHours int `json:"downloadLinkHours,omitempty"` // How long links to reports too large to be emailed stay valid, default 7 days
 }
 
 // App implements the backend plugin
@@ -115,6 +151,7 @@ type App struct {
 	scheduler  *cron.Cron
 	jobs       map[string]Job
 	cronIDs    map[string]cron.EntryID
+	dataDir    string
 	jobsFile   string
 	mu         sync.RWMutex
 	
@@ -122,6 +159,51 @@ type App struct {
 	configFile string
 	configMu   sync.RWMutex
 	
+	// Runs in progress, by run ID, and recently finished runs, persisted to runsFile
+	runs          map[string]*activeRun
+	runHistory    []Run
+	interrupted   []Run
+	runsFile      string
+	runsMu        sync.Mutex
+	shutdownGrace time.Duration
+	
+	// Last scheduled fire time of each job, persisted to scheduleFile to catch up missed runs
+	lastFires    map[string]time.Time
+	scheduleFile string
+	lastFiresMu  sync.Mutex
+	
+	// Business calendars, by ID
+	calendars     map[string]Calendar
+	calendarsFile string
+	calendarsMu   sync.RWMutex
+	
+	// Uploaded files such as logos, one file per asset
+	assetsDir string
+	
+	// Report files kept for download, by job, date and run, and the janitor applying retention rules
+	archiveDir  string
+	archiveMu   sync.Mutex
+	stopJanitor func()
+	
+	// Single-use download links already used, persisted to usedLinksFile
+	usedLinksFile string
+	usedLinksMu   sync.Mutex
+	
+	// Coordinates which replica fires each job occurrence; nil when running a single instance
+	coordinator *coordinator
+	
+	// Secrets from Grafana's secureJsonData, which take precedence over the config file
+	secureAPIKey       string
+	secureSMTPPassword string
+	
+	// Key used to encrypt secrets stored in the config file
+	key         []byte
+	secretKeyMu sync.Mutex
+	
+	// Provisioning from YAML files
+	provisioningDir string
+	provisionedSMTP *provisionedSMTP
+	
 	// Legacy fields for backward compatibility
 	grafanaURL string
 	apiKey     string
@@ -135,8 +217,36 @@ func NewApp(ctx context.Context, settings backend.AppInstanceSettings) (instance
 		scheduler:  cron.New(),
 		jobs:       make(map[string]Job),
 		cronIDs:    make(map[string]cron.EntryID),
-		jobsFile:   "/var/lib/grafana/plugin-data/progressio-grafanareporter-app/jobs.json",
-		configFile: "/var/lib/grafana/plugin-data/progressio-grafanareporter-app/config.json",
+		runs:       make(map[string]*activeRun),
+		dataDir:    pluginDataDir,
+		jobsFile:   filepath.Join(pluginDataDir, "jobs.json"),
+		configFile: filepath.Join(pluginDataDir, "config.json"),
+		runsFile:   filepath.Join(pluginDataDir, "runs.json"),
+		
+		scheduleFile:  filepath.Join(pluginDataDir, "schedule.json"),
+		calendarsFile: filepath.Join(pluginDataDir, "calendars.json"),
+		assetsDir:     filepath.Join(pluginDataDir, "assets"),
+		archiveDir:    filepath.Join(pluginDataDir, "archive"),
+		usedLinksFile: filepath.Join(pluginDataDir, "used-links.json"),
+		shutdownGrace: defaultShutdownGrace,
+	}
+	
+	if grace := os.Getenv("SHUTDOWN_GRACE_PERIOD"); grace != "" {
+		if d, err := time.ParseDuration(grace); err == nil && d >= 0 {
+			app.shutdownGrace = d
+		} else {
+			log.DefaultLogger.Warn("Invalid SHUTDOWN_GRACE_PERIOD environment variable, using default", "value", grace, "default", defaultShutdownGrace)
+		}
+	}
+	
+	app.provisioningDir = os.Getenv("PROVISIONING_DIR")
+	if app.provisioningDir == "" {
+		app.provisioningDir = filepath.Join(app.dataDir, "provisioning")
+	}
+	
+	if dir := os.Getenv("HA_COORDINATION_DIR"); dir != "" {
+		app.coordinator = newCoordinator(dir, os.Getenv("HA_REPLICA_ID"))
+		log.DefaultLogger.Info("Coordinating scheduled runs with other replicas", "dir", dir, "replica", app.coordinator.replica)
 	}
 	
 	// Load configuration from file
@@ -159,6 +269,13 @@ func NewApp(ctx context.Context, settings backend.AppInstanceSettings) (instance
 		if key, ok := settings.DecryptedSecureJSONData["apiKey"]; ok {
 			app.apiKey = key
 		}
+		
+		// Secrets managed by Grafana's encrypted secureJsonData
+		app.configMu.Lock()
+		app.secureAPIKey = settings.DecryptedSecureJSONData["grafanaApiKey"]
+		app.secureSMTPPassword = settings.DecryptedSecureJSONData["smtpPassword"]
+		app.applySecureSettings()
+		app.configMu.Unlock()
 	}
 	
 	// Use config values if set, otherwise use legacy values or defaults
@@ -204,11 +321,24 @@ func NewApp(ctx context.Context, settings backend.AppInstanceSettings) (instance
 		}
 	}
 	
-	// Load jobs from file
+	// Load calendars and jobs from file
+	if err := app.loadCalendars(); err != nil {
+		log.DefaultLogger.Warn("Failed to load calendars", "error", err)
+	}
 	if err := app.loadJobs(); err != nil {
 		log.DefaultLogger.Warn("Failed to load jobs", "error", err)
 	}
 	
+	// Apply provisioning files on top of stored jobs and config
+	if err := app.applyProvisioning(); err != nil {
+		log.DefaultLogger.Warn("Failed to apply provisioning", "error", err)
+	}
+	
+	// Load the last fire time of each job, to detect runs missed while the plugin was down
+	if err := app.loadLastFires(); err != nil {
+		log.DefaultLogger.Warn("Failed to load schedule state", "error", err)
+	}
+	
 	// Start scheduler
 	app.scheduler.Start()
 	
@@ -219,25 +349,91 @@ func NewApp(ctx context.Context, settings backend.AppInstanceSettings) (instance
 		}
 	}
 	
+	// Resume or abort runs interrupted by the previous shutdown
+	if err := app.recoverRuns(); err != nil {
+		log.DefaultLogger.Warn("Failed to recover interrupted runs", "error", err)
+	}
+	
+	// Apply the misfire policy of jobs that missed runs while the plugin was down,
+	// then disable the jobs that have no occurrence left
+	now := time.Now()
+	app.catchUpMissedRuns(now)
+	app.expireJobs(now)
+	
+	// Remove the archived reports that retention rules no longer keep, now and periodically
+	app.startArchiveJanitor()
+	
 	// Set up resource handler
-	mux := http.NewServeMux()
-	mux.HandleFunc("/jobs", app.handleJobs)
-	mux.HandleFunc("/jobs/", app.handleJobByID)
-	mux.HandleFunc("/config", app.handleConfig)
-	mux.HandleFunc("/test-email", app.handleTestEmail)
-	mux.HandleFunc("/dashboards", app.handleDashboards)
-	mux.HandleFunc("/version", app.handleVersion)
-	mux.HandleFunc("/reload", app.handleReload)
-	app.CallResourceHandler = httpadapter.New(mux)
+	app.CallResourceHandler = httpadapter.New(app.routes())
 	
 	return app, nil
 }
 
-// Dispose cleans up resources
+// routes builds the resource router, with the minimum Grafana role required by each route
+func (app *App) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs", app.authorize(routeRoles{http.MethodGet: roleViewer, "*": roleEditor}, app.handleJobs))
+	jobByID := app.authorize(routeRoles{http.MethodGet: roleViewer, "*": roleEditor}, app.handleJobByID)
+	subscribers := app.authorize(routeRoles{"*": roleViewer}, app.handleJobByID)
+	mux.HandleFunc("/jobs/", func(w http.ResponseWriter, r *http.Request) {
+		// Any user can manage their own subscription, without edit rights on the job
+		if strings.HasSuffix(r.URL.Path, "/subscribers") {
+			subscribers(w, r)
+			return
+		}
+		jobByID(w, r)
+	})
+	mux.HandleFunc("/jobs/export", app.authorize(routeRoles{"*": roleEditor}, app.handleExportJobs))
+	mux.HandleFunc("/jobs/import", app.authorize(routeRoles{"*": roleAdmin}, app.handleImportJobs))
+	mux.HandleFunc("/config", app.authorize(routeRoles{"*": roleAdmin}, app.handleConfig))
+	mux.HandleFunc("/test-email", app.authorize(routeRoles{"*": roleEditor}, app.handleTestEmail))
+	mux.HandleFunc("/calendars", app.authorize(routeRoles{http.MethodGet: roleViewer, "*": roleAdmin}, app.handleCalendars))
+	mux.HandleFunc("/calendars/", app.authorize(routeRoles{http.MethodGet: roleViewer, "*": roleAdmin}, app.handleCalendarByID))
+	mux.HandleFunc("/assets", app.authorize(routeRoles{"*": roleViewer}, app.handleAssets))
+	mux.HandleFunc("/assets/", app.authorize(routeRoles{http.MethodGet: roleViewer, "*": roleAdmin}, app.handleAssetByName))
+	mux.HandleFunc("/reports/", app.authorize(routeRoles{"*": roleViewer}, app.handleReportDownload))
+	mux.HandleFunc("/cron/preview", app.authorize(routeRoles{"*": roleViewer}, app.handleCronPreview))
+	mux.HandleFunc("/dashboards", app.authorize(routeRoles{"*": roleViewer}, app.handleDashboards))
+	mux.HandleFunc("/version", app.authorize(routeRoles{"*": roleViewer}, app.handleVersion))
+	mux.HandleFunc("/reload", app.authorize(routeRoles{"*": roleAdmin}, app.handleReload))
+	mux.HandleFunc("/runs", app.authorize(routeRoles{"*": roleViewer}, app.handleRuns))
+	mux.HandleFunc("/runs/", app.authorize(routeRoles{"*": roleEditor}, app.handleRunByID))
+	mux.Handle("/metrics", app.authorize(routeRoles{"*": roleViewer}, promhttp.Handler().ServeHTTP))
+	return mux
+}
+
+// Dispose stops the scheduler and waits up to the shutdown grace period for runs in progress,
+// then aborts the remaining runs. Aborted runs stay recorded as in progress so the next instance can recover them.
 func (app *App) Dispose() {
 	log.DefaultLogger.Info("Disposing app instance")
+	
+	if app.stopJanitor != nil {
+		app.stopJanitor()
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), app.shutdownGrace)
+	defer cancel()
+	
 	if app.scheduler != nil {
-		app.scheduler.Stop()
+		// The returned context is done once the running scheduled jobs have completed
+		select {
+		case <-app.scheduler.Stop().Done():
+		case <-ctx.Done():
+		}
+	}
+	
+	if app.waitForRuns(ctx) {
+		return
+	}
+	
+	log.DefaultLogger.Warn("Shutdown grace period elapsed, aborting runs in progress", "grace", app.shutdownGrace, "runs", len(app.activeRuns()))
+	app.cancelAllRuns(errShutdown)
+	
+	// Give the aborted runs a moment to record their state
+	abortCtx, cancelAbort := context.WithTimeout(context.Background(), shutdownAbortWait)
+	defer cancelAbort()
+	if !app.waitForRuns(abortCtx) {
+		log.DefaultLogger.Warn("Runs did not stop after being aborted", "runs", len(app.activeRuns()))
 	}
 }
 
@@ -272,6 +468,15 @@ func (app *App) loadJobs() error {
 	}
 	
 	for _, job := range jobs {
+		if job.pdfEncryption() != nil {
+			key, err := app.secretKey()
+			if err != nil {
+				return err
+			}
+			if job, err = job.openSecrets(key); err != nil {
+				return err
+			}
+		}
 		app.jobs[job.ID] = job
 	}
 	
@@ -288,6 +493,20 @@ func (app *App) saveJobs() error {
 	}
 	app.mu.RUnlock()
 	
+	// PDF passwords are encrypted at rest, like the configuration secrets
+	for i, job := range jobs {
+		if job.pdfEncryption() == nil {
+			continue
+		}
+		key, err := app.secretKey()
+		if err != nil {
+			return err
+		}
+		if jobs[i], err = job.sealSecrets(key); err != nil {
+			return err
+		}
+	}
+	
 	data, err := json.MarshalIndent(jobs, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal jobs: %w", err)
@@ -300,42 +519,119 @@ func (app *App) saveJobs() error {
 	return nil
 }
 
-// loadConfig loads configuration from the JSON file
+// loadConfig loads configuration from the JSON file, migrating plaintext secrets to encrypted storage
 func (app *App) loadConfig() error {
+	migrate, err := app.readConfig()
+	if err != nil {
+		return err
+	}
+	
+	if migrate {
+		if err := app.saveConfig(); err != nil {
+			return fmt.Errorf("failed to migrate plaintext secrets: %w", err)
+		}
+		log.DefaultLogger.Info("Migrated plaintext secrets in config file to encrypted storage")
+	}
+	
+	return nil
+}
+
+// readConfig reads and decrypts the JSON config file, reporting whether it still holds plaintext secrets
+func (app *App) readConfig() (bool, error) {
 	app.configMu.Lock()
 	defer app.configMu.Unlock()
 	
 	// Create data directory if it doesn't exist
 	dir := filepath.Dir(app.configFile)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
+		return false, fmt.Errorf("failed to create config directory: %w", err)
 	}
 	
 	// Check if file exists
 	if _, err := os.Stat(app.configFile); os.IsNotExist(err) {
 		// No config file yet, use defaults
-		return nil
+		app.applySecureSettings()
+		return false, nil
 	}
 	
 	data, err := os.ReadFile(app.configFile)
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+		return false, fmt.Errorf("failed to read config file: %w", err)
 	}
 	
 	if err := json.Unmarshal(data, &app.config); err != nil {
-		return fmt.Errorf("failed to parse config file: %w", err)
+		return false, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	
+	key, err := app.secretKey()
+	if err != nil {
+		return false, err
+	}
+	
+	migrate := false
+	for _, secret := range []*string{&app.config.GrafanaAPIKey, &app.config.SMTPPassword} {
+		if *secret != "" && !isEncrypted(*secret) {
+			migrate = true
+			continue
+		}
+		if *secret, err = decryptSecret(key, *secret); err != nil {
+			return false, err
+		}
 	}
 	
+	app.applySecureSettings()
+	
 	log.DefaultLogger.Info("Loaded configuration from file")
-	return nil
+	return migrate, nil
+}
+
+// applySecureSettings overrides config secrets with those stored in Grafana's secureJsonData.
+// Callers must hold configMu.
+func (app *App) applySecureSettings() {
+	if app.secureAPIKey != "" {
+		app.config.GrafanaAPIKey = app.secureAPIKey
+	}
+	if app.secureSMTPPassword != "" {
+		app.config.SMTPPassword = app.secureSMTPPassword
+	}
 }
 
-// saveConfig saves configuration to the JSON file
+// currentConfig returns the configuration in effect: the stored configuration with the
+// provisioned SMTP settings applied. Callers must hold configMu.
+func (app *App) currentConfig() Config {
+	config := app.config
+	if app.provisionedSMTP != nil {
+		app.provisionedSMTP.applyTo(&config)
+	}
+	return config
+}
+
+// saveConfig saves configuration to the JSON file with secrets encrypted
 func (app *App) saveConfig() error {
 	app.configMu.RLock()
 	config := app.config
+	secureAPIKey := app.secureAPIKey
+	secureSMTPPassword := app.secureSMTPPassword
 	app.configMu.RUnlock()
 	
+	// Secrets kept in Grafana's secureJsonData are never written to disk
+	if secureAPIKey != "" {
+		config.GrafanaAPIKey = ""
+	}
+	if secureSMTPPassword != "" {
+		config.SMTPPassword = ""
+	}
+	
+	key, err := app.secretKey()
+	if err != nil {
+		return err
+	}
+	for _, secret := range []*string{&config.GrafanaAPIKey, &config.SMTPPassword} {
+		if *secret, err = encryptSecret(key, *secret); err != nil {
+			return fmt.Errorf("failed to encrypt secret: %w", err)
+		}
+	}
+	
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
@@ -360,23 +656,67 @@ func (app *App) scheduleJob(job Job) error {
 		delete(app.cronIDs, job.ID)
 	}
 	
-	// Add new schedule
-	entryID, err := app.scheduler.AddFunc(job.Cron, func() {
-		if err := app.executeJob(job); err != nil {
-			log.DefaultLogger.Error("Failed to execute job", "id", job.ID, "error", err)
-		}
-	})
+	if job.Disabled {
+		metricJobsScheduled.Set(float64(len(app.cronIDs)))
+		log.DefaultLogger.Info("Job is disabled, not scheduling it", "id", job.ID)
+		return nil
+	}
 	
+	schedule, err := app.jobSchedule(job)
 	if err != nil {
 		return fmt.Errorf("failed to schedule job: %w", err)
 	}
 	
+	// Add new schedule
+	entryID := app.scheduler.Schedule(schedule, cron.FuncJob(func() {
+		// Subscriptions change the stored job without rescheduling it
+		job := job
+		app.mu.RLock()
+		if current, ok := app.jobs[job.ID]; ok {
+			job = current
+		}
+		app.mu.RUnlock()
+		
+		occurrence := app.scheduledOccurrence(job)
+		app.recordFire(job.ID, occurrence, false)
+		app.runOccurrence(job, occurrence, triggerSchedule)
+		
+		// Disable jobs that fired their last occurrence
+		app.expireJob(job.ID, time.Now())
+	}))
+	
 	app.cronIDs[job.ID] = entryID
+	metricJobsScheduled.Set(float64(len(app.cronIDs)))
+	
+	// Count missed runs of new jobs from when they were scheduled
+	app.recordFire(job.ID, time.Now(), true)
 	log.DefaultLogger.Info("Scheduled job", "id", job.ID, "cron", job.Cron)
 	
 	return nil
 }
 
+// scheduledOccurrence returns the occurrence a job is being fired for: the time the scheduler planned
+// the run for, rather than the time it actually fired, so that every replica claims the same
+// occurrence even when one of them fires late
+func (app *App) scheduledOccurrence(job Job) time.Time {
+	if job.RunAt != nil {
+		return *job.RunAt
+	}
+	
+	app.mu.RLock()
+	entryID, ok := app.cronIDs[job.ID]
+	app.mu.RUnlock()
+	if ok {
+		// The scheduler sets Prev before answering other calls, so it is the time of this run
+		if entry := app.scheduler.Entry(entryID); !entry.Prev.IsZero() {
+			return entry.Prev
+		}
+	}
+	
+	// The job was rescheduled while firing
+	return time.Now().Truncate(time.Second)
+}
+
 // unscheduleJob removes a job from the scheduler
 func (app *App) unscheduleJob(jobID string) {
 	app.mu.Lock()
@@ -385,31 +725,74 @@ func (app *App) unscheduleJob(jobID string) {
 	if entryID, ok := app.cronIDs[jobID]; ok {
 		app.scheduler.Remove(entryID)
 		delete(app.cronIDs, jobID)
+		metricJobsScheduled.Set(float64(len(app.cronIDs)))
 		log.DefaultLogger.Info("Unscheduled job", "id", jobID)
 	}
 }
 
 // executeJob executes a scheduled job
-func (app *App) executeJob(job Job) error {
+func (app *App) executeJob(ctx context.Context, job Job) (err error) {
+	ctx, span := startSpan(ctx, "executeJob", jobAttributes(job)...)
+	defer func() { endSpan(span, err) }()
+	
 	log.DefaultLogger.Info("Executing job", "id", job.ID)
 	
-	// Render the report
-	imageData, err := app.renderReport(job)
+	metricExecutionsInProgress.Inc()
+	defer metricExecutionsInProgress.Dec()
+	
+	// Render the report, for both periods when comparing
+	renderStart := time.Now()
+	var imageData []byte
+	var periods []renderedPeriod
+	if job.CompareOffset != "" {
+		imageData, periods, err = app.renderComparison(ctx, job)
+	} else {
+		imageData, err = app.renderImage(ctx, job)
+	}
+	if err == nil {
+		imageData, periods, err = job.encodeImages(imageData, periods)
+	}
 	if err != nil {
+		metricExecutions.WithLabelValues(outcomeRenderError).Inc()
 		return fmt.Errorf("failed to render report: %w", err)
 	}
 	
+	// PDF reports are laid out on printable pages around the rendered image, with a document
+	// per recipient when each recipient has their own password
+	deliveries := []delivery{{recipients: job.deliveryRecipients(), attachment: imageData}}
+	if job.Format == "pdf" {
+		if deliveries, err = app.pdfDeliveries(job, deliveries[0].recipients, imageData, periods); err != nil {
+			metricExecutions.WithLabelValues(outcomeRenderError).Inc()
+			return fmt.Errorf("failed to compose PDF: %w", err)
+		}
+	}
+	metricRenderDuration.WithLabelValues(job.Format).Observe(time.Since(renderStart).Seconds())
+	for _, d := range deliveries {
+		metricRenderedBytes.WithLabelValues(job.Format).Add(float64(len(d.attachment)))
+	}
+	
 	// Send email
-	if err := app.sendEmail(job, imageData); err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+	emailStart := time.Now()
+	for _, d := range deliveries {
+		if err := app.sendEmail(ctx, job, d.recipients, d.attachment, periods); err != nil {
+			metricExecutions.WithLabelValues(outcomeEmailError).Inc()
+			return fmt.Errorf("failed to send email: %w", err)
+		}
 	}
+	metricEmailDuration.Observe(time.Since(emailStart).Seconds())
+	
+	metricExecutions.WithLabelValues(outcomeSuccess).Inc()
+	metricLastSuccess.WithLabelValues(job.ID).SetToCurrentTime()
 	
 	log.DefaultLogger.Info("Job executed successfully", "id", job.ID)
 	return nil
 }
 
 // renderReport renders a dashboard or panel to PNG/PDF
-func (app *App) renderReport(job Job) ([]byte, error) {
+func (app *App) renderReport(ctx context.Context, job Job) (data []byte, err error) {
+	ctx, span := startSpan(ctx, "renderReport", jobAttributes(job)...)
+	defer func() { endSpan(span, err) }()
+	
 	// Get Grafana URL from config
 	app.configMu.RLock()
 	grafanaURL := app.config.GrafanaURL
@@ -417,37 +800,12 @@ func (app *App) renderReport(job Job) ([]byte, error) {
 	app.configMu.RUnlock()
 	
 	// Build render URL
-	var renderURL string
-	if job.PanelID != nil {
-		// Render single panel
-		renderURL = fmt.Sprintf("%s/render/d-solo/%s/%s?panelId=%d&from=%s&to=%s&width=%d&height=%d&scale=%d&tz=UTC",
-			grafanaURL, job.DashboardUID, job.Slug, *job.PanelID, job.From, job.To, job.Width, job.Height, job.Scale)
-	} else {
-		// Render full dashboard
-		renderURL = fmt.Sprintf("%s/render/d/%s/%s?from=%s&to=%s&width=%d&height=%d&scale=%d&kiosk&tz=UTC",
-			grafanaURL, job.DashboardUID, job.Slug, job.From, job.To, job.Width, job.Height, job.Scale)
-	}
-	
-	// Add variables to the URL if present
-	if len(job.Variables) > 0 {
-		totalVars := 0
-		for _, values := range job.Variables {
-			totalVars += len(values)
-		}
-		log.DefaultLogger.Info("Adding variables to render URL", "count", totalVars)
-		for key, values := range job.Variables {
-			// Support multiple values for the same variable key
-			for _, value := range values {
-				renderURL += fmt.Sprintf("&var-%s=%s", url.QueryEscape(key), url.QueryEscape(value))
-				log.DefaultLogger.Debug("Added variable", "key", key, "value", value)
-			}
-		}
-	}
+	renderURL := app.buildRenderURL(grafanaURL, job)
 	
 	log.DefaultLogger.Info("Rendering report", "url", renderURL, "format", job.Format)
 	
 	// Create HTTP request
-	req, err := http.NewRequest("GET", renderURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", renderURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -457,43 +815,52 @@ func (app *App) renderReport(job Job) ([]byte, error) {
 		req.Header.Set("Authorization", "Bearer "+apiKey)
 	}
 	
-	// Execute request
-	client := &http.Client{
-		Timeout: 60 * time.Second,
-	}
-	resp, err := client.Do(req)
+	// Continue the trace in Grafana and the renderer
+	injectTraceHeaders(ctx, req)
+	
+	// Execute request; the run's context bounds how long rendering may take
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 	
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("render request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 	
 	// Read response body
-	data, err := io.ReadAll(resp.Body)
+	data, err = io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 	
+	span.SetAttributes(attribute.Int("render.bytes", len(data)))
 	return data, nil
 }
 
-// sendEmail sends an email with the rendered report
-func (app *App) sendEmail(job Job, attachment []byte) error {
-	log.DefaultLogger.Info("Sending email", "recipients", job.Recipients, "subject", job.Subject)
+// sendEmail sends an email with the rendered report to recipients, and with the report of each period for comparisons in HTML
+func (app *App) sendEmail(ctx context.Context, job Job, recipients []string, attachment []byte, periods []renderedPeriod) (err error) {
+	ctx, span := startSpan(ctx, "sendEmail", jobAttributes(job)...)
+	defer func() { endSpan(span, err) }()
+	
+	span.SetAttributes(attribute.Int("email.recipients", len(recipients)))
+	log.DefaultLogger.Info("Sending email", "recipients", recipients, "subject", job.Subject)
 	
 	// Get SMTP configuration from config
 	app.configMu.RLock()
-	smtpHost := app.config.SMTPHost
-	smtpPort := fmt.Sprintf("%d", app.config.SMTPPort)
-	smtpUser := app.config.SMTPUser
-	smtpPass := app.config.SMTPPassword
-	smtpFrom := app.config.SMTPFrom
-	grafanaURL := app.config.GrafanaURL
+	config := app.currentConfig()
 	app.configMu.RUnlock()
+	smtpHost := config.SMTPHost
+	smtpPort := fmt.Sprintf("%d", config.SMTPPort)
+	smtpUser := config.SMTPUser
+	smtpPass := config.SMTPPassword
+	smtpFrom := config.SMTPFrom
+	grafanaURL := config.GrafanaURL
+	maxEmailBytes := config.MaxEmailBytes
 	
 	if smtpHost == "" {
 		return fmt.Errorf("SMTP_HOST not configured")
@@ -507,23 +874,121 @@ func (app *App) sendEmail(job Job, attachment []byte) error {
 		smtpPort = "587"
 	}
 	
-	// Create email sender
-	sender := NewEmailSender(smtpHost, smtpPort, smtpUser, smtpPass, smtpFrom)
+	// Create email sender, aborted with the run
+	sender := NewEmailSender(smtpHost, smtpPort, smtpUser, smtpPass, smtpFrom).WithContext(ctx).WithMaxMessageSize(maxEmailBytes)
+	
+	// Reports too large for the SMTP server are archived and linked instead of sent
+	var tooLarge *MessageTooLargeError
+	timestamp := time.Now().Format("2006-01-02-150405")
+	imageExtension := job.imageExtension()
 	
 	// Check if HTML format is requested
 	if job.Format == "html" {
 		// Build dashboard URL for linking
 		dashboardURL := app.buildDashboardURL(grafanaURL, job)
 		
-		// For HTML format, render as PNG and embed the image in the email body with a link to the live dashboard
-		return sender.SendHTML(job.Recipients, job.Subject, job.Body, attachment, "png", dashboardURL)
+		files := []reportFile{{name: fmt.Sprintf("report-%s.%s", timestamp, imageExtension), data: attachment}}
+		if len(periods) > 0 {
+			// Comparisons have a file for each period
+			files = make([]reportFile, len(periods))
+			for i, period := range periods {
+				files[i] = reportFile{name: fmt.Sprintf("report-%s-%d.%s", timestamp, i+1, imageExtension), data: period.data}
+			}
+		}
+		if job.Delivery == deliveryLink {
+			return app.sendDownloadLinks(ctx, sender, job, recipients, files)
+		}
+		
+		if len(periods) > 0 {
+			// Comparisons show the image of each period, with its label
+			images := make([]InlineImage, len(periods))
+			for i, period := range periods {
+				images[i] = InlineImage{Data: period.data, ContentType: job.imageContentType(), Caption: period.label}
+			}
+			err = sender.SendHTMLImages(recipients, job.Subject, job.Body, images)
+		} else {
+			// For HTML format, embed the rendered image in the email body with a link to the live dashboard
+			err = sender.SendHTML(recipients, job.Subject, job.Body, attachment, job.imageFormat(), dashboardURL)
+		}
+		if errors.As(err, &tooLarge) {
+			log.DefaultLogger.Warn("Report too large to be emailed, sending download links", "id", job.ID, "bytes", tooLarge.Size, "limit", tooLarge.Limit)
+			return app.sendDownloadLinks(ctx, sender, job, recipients, files)
+		}
+		if err == nil {
+			app.archiveReport(ctx, job, files)
+		}
+		return err
 	}
 	
 	// Determine attachment filename for non-HTML formats
-	filename := fmt.Sprintf("report-%s.%s", time.Now().Format("2006-01-02-150405"), job.Format)
+	extension := job.Format
+	if job.Format == "png" {
+		extension = imageExtension
+	}
+	filename := fmt.Sprintf("report-%s.%s", timestamp, extension)
+	if job.Delivery == deliveryLink {
+		return app.sendDownloadLinks(ctx, sender, job, recipients, []reportFile{{name: filename, data: attachment}})
+	}
 	
 	// Send email with attachment
-	return sender.Send(job.Recipients, job.Subject, job.Body, attachment, filename)
+	err = sender.Send(recipients, job.Subject, job.Body, attachment, filename)
+	if errors.As(err, &tooLarge) {
+		log.DefaultLogger.Warn("Report too large to be emailed, sending a download link", "id", job.ID, "bytes", tooLarge.Size, "limit", tooLarge.Limit)
+		return app.sendDownloadLinks(ctx, sender, job, recipients, []reportFile{{name: filename, data: attachment}})
+	}
+	if err == nil {
+		app.archiveReport(ctx, job, []reportFile{{name: filename, data: attachment}})
+	}
+	return err
+}
+
+// buildRenderURL builds the image renderer URL of the dashboard or panel, with the job's render options
+func (app *App) buildRenderURL(grafanaURL string, job Job) string {
+	options := job.renderOptions()
+	
+	// Times in the report are shown in the job's time zone, which its time range is computed in
+	tz := "UTC"
+	if loc, err := job.location(); err == nil {
+		tz = loc.String()
+	}
+	
+	// Grafana passes scale to the renderer as the device scale factor
+	scale := strconv.Itoa(job.Scale)
+	if options.DeviceScaleFactor > 0 {
+		scale = strconv.FormatFloat(options.DeviceScaleFactor, 'f', -1, 64)
+	}
+	
+	// Build render URL
+	var renderURL string
+	if job.PanelID != nil {
+		// Render single panel
+		renderURL = fmt.Sprintf("%s/render/d-solo/%s/%s?panelId=%d&from=%s&to=%s&width=%d&height=%d&scale=%s&tz=%s",
+			grafanaURL, job.DashboardUID, job.Slug, *job.PanelID, job.From, job.To, job.Width, job.Height, scale, url.QueryEscape(tz))
+	} else {
+		// Render full dashboard
+		renderURL = fmt.Sprintf("%s/render/d/%s/%s?from=%s&to=%s&width=%d&height=%d&scale=%s&tz=%s",
+			grafanaURL, job.DashboardUID, job.Slug, job.From, job.To, job.Width, job.Height, scale, url.QueryEscape(tz))
+		renderURL += options.dashboardParams()
+	}
+	renderURL += options.params()
+	
+	// Add variables to the URL if present
+	if len(job.Variables) > 0 {
+		totalVars := 0
+		for _, values := range job.Variables {
+			totalVars += len(values)
+		}
+		log.DefaultLogger.Info("Adding variables to render URL", "count", totalVars)
+		for key, values := range job.Variables {
+			// Support multiple values for the same variable key
+			for _, value := range values {
+				renderURL += fmt.Sprintf("&var-%s=%s", url.QueryEscape(key), url.QueryEscape(value))
+				log.DefaultLogger.Debug("Added variable", "key", key, "value", value)
+			}
+		}
+	}
+	
+	return renderURL
 }
 
 // buildDashboardURL builds a URL to the dashboard with all parameters
@@ -539,6 +1004,11 @@ func (app *App) buildDashboardURL(grafanaURL string, job Job) string {
 			grafanaURL, job.DashboardUID, job.Slug, url.QueryEscape(job.From), url.QueryEscape(job.To))
 	}
 	
+	// Open the dashboard in the organization it was rendered from
+	if orgID := job.renderOptions().OrgID; orgID > 0 {
+		dashboardURL += fmt.Sprintf("&orgId=%d", orgID)
+	}
+	
 	// Add variables to the URL if present
 	if len(job.Variables) > 0 {
 		for key, values := range job.Variables {
@@ -576,6 +1046,24 @@ func (app *App) handleJobByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	// Check if it's a subscription request
+	if strings.HasSuffix(path, "/subscribers") {
+		app.handleSubscribers(w, r, strings.TrimSuffix(path, "/subscribers"))
+		return
+	}
+	
+	// Check if it's a request for the passwords of encrypted PDF reports
+	if strings.HasSuffix(path, "/pdf-passwords") {
+		app.handlePDFPasswords(w, r, strings.TrimSuffix(path, "/pdf-passwords"))
+		return
+	}
+	
+	// Check if it's a request for the archived reports, or one of their files
+	if jobID, filePath, ok := strings.Cut(path, "/archive"); ok && (filePath == "" || strings.HasPrefix(filePath, "/")) {
+		app.handleJobArchive(w, r, jobID, strings.TrimPrefix(filePath, "/"))
+		return
+	}
+	
 	// Otherwise, path is the job ID
 	jobID := path
 
@@ -599,8 +1087,15 @@ func (app *App) listJobs(w http.ResponseWriter, r *http.Request) {
 	}
 	app.mu.RUnlock()
 	
+	// Include the next and previous fire times from the scheduler
+	user := requestUser(r)
+	result := make([]jobWithSchedule, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, app.withSchedule(job.visibleTo(user)))
+	}
+	
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(jobs)
+	json.NewEncoder(w).Encode(result)
 }
 
 func (app *App) getJob(w http.ResponseWriter, r *http.Request, jobID string) {
@@ -614,7 +1109,36 @@ func (app *App) getJob(w http.ResponseWriter, r *http.Request, jobID string) {
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(job)
+	json.NewEncoder(w).Encode(job.visibleTo(requestUser(r)).redacted())
+}
+
+// validateJob checks a job created or updated through the API, imported or provisioned. The
+// errors of the schedule, calendar and assets say which part of the job is invalid.
+func (app *App) validateJob(job Job) error {
+	if err := job.validateSchedule(); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	if err := app.checkJobCalendar(job); err != nil {
+		return fmt.Errorf("invalid calendar: %w", err)
+	}
+	if err := app.checkJobAssets(job); err != nil {
+		return fmt.Errorf("invalid asset: %w", err)
+	}
+	for _, validate := range []func() error{
+		job.validateMisfirePolicy,
+		job.validateTimeRange,
+		job.validateCompareOffset,
+		job.validateRenderOptions,
+		job.validatePDFOptions,
+		job.validateImageOptions,
+		job.validateDelivery,
+		job.validateArchive,
+	} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (app *App) createJob(w http.ResponseWriter, r *http.Request) {
@@ -629,14 +1153,38 @@ func (app *App) createJob(w http.ResponseWriter, r *http.Request) {
 		job.ID = fmt.Sprintf("job-%d", time.Now().UnixNano())
 	}
 	
-	// Validate cron expression
-	if _, err := cron.ParseStandard(job.Cron); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid cron expression: %v", err), http.StatusBadRequest)
+	// Only provisioning files can create provisioned jobs
+	job.Provisioned = false
+	
+	// The creating user owns the job; subscribers sign up themselves
+	user := requestUser(r)
+	job.Subscribers = nil
+	job.Owner = ""
+	if user != nil {
+		job.Owner = user.Login
+	}
+	
+	// Validate the job
+	if err := app.validateJob(job); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := job.validateRunAt(nil, time.Now()); err != nil {
+		http.Error(w, fmt.Sprintf("invalid schedule: %v", err), http.StatusBadRequest)
 		return
 	}
 	
-	// Add job
+	// Add job, unless it would replace a provisioned one
 	app.mu.Lock()
+	if existing, ok := app.jobs[job.ID]; ok && existing.Provisioned {
+		app.mu.Unlock()
+		http.Error(w, "Job is provisioned and cannot be modified", http.StatusForbidden)
+		return
+	} else if ok && !canManageJob(user, existing) {
+		app.mu.Unlock()
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
 	app.jobs[job.ID] = job
 	app.mu.Unlock()
 	
@@ -653,7 +1201,7 @@ func (app *App) createJob(w http.ResponseWriter, r *http.Request) {
 	
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(job)
+	json.NewEncoder(w).Encode(job.redacted())
 }
 
 func (app *App) updateJob(w http.ResponseWriter, r *http.Request, jobID string) {
@@ -665,16 +1213,27 @@ func (app *App) updateJob(w http.ResponseWriter, r *http.Request, jobID string)
 	
 	// Ensure ID matches
 	job.ID = jobID
+	job.Provisioned = false
 	
-	// Validate cron expression
-	if _, err := cron.ParseStandard(job.Cron); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid cron expression: %v", err), http.StatusBadRequest)
+	// PDF passwords are returned as placeholders; posting them back keeps the stored values
+	app.mu.RLock()
+	previous := app.jobs[jobID]
+	app.mu.RUnlock()
+	job = job.restoreSecrets(previous)
+	
+	// Validate the job
+	if err := app.validateJob(job); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := job.validateRunAt(previous.RunAt, time.Now()); err != nil {
+		http.Error(w, fmt.Sprintf("invalid schedule: %v", err), http.StatusBadRequest)
 		return
 	}
 	
 	// Check if job exists
 	app.mu.RLock()
-	_, exists := app.jobs[jobID]
+	existing, exists := app.jobs[jobID]
 	app.mu.RUnlock()
 	
 	if !exists {
@@ -682,6 +1241,20 @@ func (app *App) updateJob(w http.ResponseWriter, r *http.Request, jobID string)
 		return
 	}
 	
+	if !canManageJob(requestUser(r), existing) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	
+	if existing.Provisioned {
+		http.Error(w, "Job is provisioned and cannot be modified", http.StatusForbidden)
+		return
+	}
+	
+	// Ownership and subscriptions cannot be changed through updates
+	job.Owner = existing.Owner
+	job.Subscribers = existing.Subscribers
+	
 	// Update job
 	app.mu.Lock()
 	app.jobs[jobID] = job
@@ -699,13 +1272,13 @@ func (app *App) updateJob(w http.ResponseWriter, r *http.Request, jobID string)
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(job)
+	json.NewEncoder(w).Encode(job.redacted())
 }
 
 func (app *App) deleteJob(w http.ResponseWriter, r *http.Request, jobID string) {
 	// Check if job exists
 	app.mu.RLock()
-	_, exists := app.jobs[jobID]
+	existing, exists := app.jobs[jobID]
 	app.mu.RUnlock()
 	
 	if !exists {
@@ -713,6 +1286,16 @@ func (app *App) deleteJob(w http.ResponseWriter, r *http.Request, jobID string)
 		return
 	}
 	
+	if !canManageJob(requestUser(r), existing) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	
+	if existing.Provisioned {
+		http.Error(w, "Job is provisioned and cannot be deleted", http.StatusForbidden)
+		return
+	}
+	
 	// Unschedule job
 	app.unscheduleJob(jobID)
 	
@@ -720,6 +1303,7 @@ func (app *App) deleteJob(w http.ResponseWriter, r *http.Request, jobID string)
 	app.mu.Lock()
 	delete(app.jobs, jobID)
 	app.mu.Unlock()
+	metricLastSuccess.DeleteLabelValues(jobID)
 	
 	// Save to file
 	if err := app.saveJobs(); err != nil {
@@ -740,16 +1324,19 @@ func (app *App) executeJobHandler(w http.ResponseWriter, r *http.Request, jobID
 		return
 	}
 	
-	// Execute job asynchronously
-	go func() {
-		if err := app.executeJob(job); err != nil {
-			log.DefaultLogger.Error("Failed to execute job", "id", jobID, "error", err)
-		}
-	}()
+	if !canManageJob(requestUser(r), job) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	
+	// Execute job asynchronously, keeping the request's trace but not its cancellation
+	active := app.startRun(r.Context(), job, triggerManual)
+	go app.executeRun(active, job)
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "Job execution started",
+		"runId":   active.run.ID,
 	})
 }
 
@@ -780,7 +1367,7 @@ func (app *App) handleTestEmail(w http.ResponseWriter, r *http.Request) {
 		Format:     "test", // Using "test" format to distinguish from actual report formats
 	}
 	
-	if err := app.sendEmail(testJob, testMessage); err != nil {
+	if err := app.sendEmail(r.Context(), testJob, testJob.deliveryRecipients(), testMessage, nil); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to send test email: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -804,28 +1391,37 @@ func (app *App) handleConfig(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) getConfig(w http.ResponseWriter, r *http.Request) {
 	app.configMu.RLock()
-	config := app.config
+	config := app.currentConfig()
+	smtpProvisioned := app.provisionedSMTP != nil
 	app.configMu.RUnlock()
 	
-	// Create a response config with masked sensitive data
+	// Create a response config without sensitive data
 	type ConfigResponse struct {
-		GrafanaURL    string `json:"grafanaUrl"`
-		GrafanaAPIKey string `json:"grafanaApiKey"`
-		SMTPHost      string `json:"smtpHost"`
-		SMTPPort      int    `json:"smtpPort"`
-		SMTPUser      string `json:"smtpUser"`
-		SMTPPassword  string `json:"smtpPassword"`
-		SMTPFrom      string `json:"smtpFrom"`
+		GrafanaURL      string `json:"grafanaUrl"`
+		GrafanaAPIKey   string `json:"grafanaApiKey"`
+		SMTPHost        string `json:"smtpHost"`
+		SMTPPort        int    `json:"smtpPort"`
+		SMTPUser        string `json:"smtpUser"`
+		SMTPPassword    string `json:"smtpPassword"`
+		SMTPFrom        string `json:"smtpFrom"`
+		SMTPProvisioned bool   `json:"smtpProvisioned"`
+		FiscalYearStartMonth int `json:"fiscalYearStartMonth,omitempty"`
+		MaxEmailBytes   int64  `json:"maxEmailBytes,omitempty"`
+		DownloadLinkHours int  `json:"downloadLinkHours,omitempty"`
 	}
 	
 	response := ConfigResponse{
-		GrafanaURL:    config.GrafanaURL,
-		GrafanaAPIKey: maskString(config.GrafanaAPIKey),
-		SMTPHost:      config.SMTPHost,
-		SMTPPort:      config.SMTPPort,
-		SMTPUser:      config.SMTPUser,
-		SMTPPassword:  maskString(config.SMTPPassword),
-		SMTPFrom:      config.SMTPFrom,
+		GrafanaURL:      config.GrafanaURL,
+		GrafanaAPIKey:   secretStatus(config.GrafanaAPIKey),
+		SMTPHost:        config.SMTPHost,
+		SMTPPort:        config.SMTPPort,
+		SMTPUser:        config.SMTPUser,
+		SMTPPassword:    secretStatus(config.SMTPPassword),
+		SMTPFrom:        config.SMTPFrom,
+		SMTPProvisioned: smtpProvisioned,
+		FiscalYearStartMonth: config.FiscalYearStartMonth,
+		MaxEmailBytes:   config.MaxEmailBytes,
+		DownloadLinkHours: config.DownloadLinkHours,
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
@@ -838,19 +1434,34 @@ func (app *App) updateConfig(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if newConfig.FiscalYearStartMonth < 0 || newConfig.FiscalYearStartMonth > 12 {
+		http.Error(w, "fiscalYearStartMonth must be between 1 and 12", http.StatusBadRequest)
+		return
+	}
+	if newConfig.MaxEmailBytes < 0 || newConfig.DownloadLinkHours < 0 {
+		http.Error(w, "maxEmailBytes and downloadLinkHours must not be negative", http.StatusBadRequest)
+		return
+	}
 	
 	// Get current config to preserve masked values
 	app.configMu.Lock()
 	
-	// If API key or password are masked (contain asterisks), keep the old value
-	if strings.Contains(newConfig.GrafanaAPIKey, "*") {
+	// If API key or password are the placeholder returned by getConfig, keep the old value
+	if newConfig.GrafanaAPIKey == secretPlaceholder {
 		newConfig.GrafanaAPIKey = app.config.GrafanaAPIKey
 	}
-	if strings.Contains(newConfig.SMTPPassword, "*") {
+	if newConfig.SMTPPassword == secretPlaceholder {
 		newConfig.SMTPPassword = app.config.SMTPPassword
 	}
 	
+	// Provisioned SMTP settings always win over values set through the API, which are not saved
+	if app.provisionedSMTP != nil {
+		app.provisionedSMTP.keepStored(&newConfig, app.config)
+	}
+	
 	app.config = newConfig
+	// Secrets from secureJsonData can only be changed in Grafana's plugin settings
+	app.applySecureSettings()
 	app.configMu.Unlock()
 	
 	// Save to file
@@ -866,33 +1477,37 @@ func (app *App) updateConfig(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// maskString masks a string by replacing all but the first and last characters with asterisks
-func maskString(s string) string {
-	if s == "" {
-		return ""
-	}
-	if len(s) <= 4 {
-		return "****"
-	}
-	return s[:2] + strings.Repeat("*", len(s)-4) + s[len(s)-2:]
-}
-
-// CheckHealth handles health check requests
+// CheckHealth handles health check requests, verifying every dependency needed to deliver reports
 func (app *App) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
 	log.DefaultLogger.Info("Checking health")
 	
+	checks := app.runHealthChecks(ctx)
+	
 	status := backend.HealthStatusOk
 	message := "Plugin is healthy"
 	
-	// Check if scheduler is running
-	if app.scheduler == nil {
+	var failed []string
+	for _, check := range checks {
+		if check.Status != checkOK {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Message))
+		}
+	}
+	if len(failed) > 0 {
 		status = backend.HealthStatusError
-		message = "Scheduler is not running"
+		message = fmt.Sprintf("%d of %d checks failed: %s", len(failed), len(checks), strings.Join(failed, "; "))
+	}
+	
+	details, err := json.Marshal(map[string]interface{}{
+		"checks": checks,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal health details: %w", err)
 	}
 	
 	return &backend.CheckHealthResult{
-		Status:  status,
-		Message: message,
+		Status:      status,
+		Message:     message,
+		JSONDetails: details,
 	}, nil
 }
 
@@ -903,6 +1518,9 @@ func (app *App) handleDashboards(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, span := startSpan(r.Context(), "handleDashboards")
+	defer span.End()
+
 	// Get Grafana URL and API key from config
 	app.configMu.RLock()
 	grafanaURL := app.config.GrafanaURL
@@ -922,7 +1540,7 @@ func (app *App) handleDashboards(w http.ResponseWriter, r *http.Request) {
 	// Make request to Grafana search API
 	// Set limit to 5000 to ensure we get all dashboards
 	searchURL := fmt.Sprintf("%s/api/search?type=dash-db&limit=5000", grafanaURL)
-	req, err := http.NewRequest("GET", searchURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create request: %v", err), http.StatusInternalServerError)
 		return
@@ -930,19 +1548,25 @@ func (app *App) handleDashboards(w http.ResponseWriter, r *http.Request) {
 
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("Content-Type", "application/json")
+	injectTraceHeaders(ctx, req)
 
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 	}
 	resp, err := client.Do(req)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		http.Error(w, fmt.Sprintf("Failed to fetch dashboards: %v", err), http.StatusInternalServerError)
 		return
 	}
 	defer resp.Body.Close()
 
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
+		span.SetStatus(codes.Error, fmt.Sprintf("Grafana API returned status %d", resp.StatusCode))
 		http.Error(w, fmt.Sprintf("Grafana API returned status %d: %s", resp.StatusCode, string(body)), http.StatusInternalServerError)
 		return
 	}
@@ -961,6 +1585,8 @@ func (app *App) handleDashboards(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	span.SetAttributes(attribute.Int("dashboards.count", len(dashboards)))
+
 	// Process each dashboard to ensure it has a slug
 	for i := range dashboards {
 		// If slug is not present, extract it from URI
@@ -1036,13 +1662,25 @@ func (app *App) handleReload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Reload jobs
+	// Reload calendars and jobs
+	if err := app.loadCalendars(); err != nil {
+		log.DefaultLogger.Error("Failed to reload calendars", "error", err)
+		http.Error(w, fmt.Sprintf("Failed to reload calendars: %v", err), http.StatusInternalServerError)
+		return
+	}
 	if err := app.loadJobs(); err != nil {
 		log.DefaultLogger.Error("Failed to reload jobs", "error", err)
 		http.Error(w, fmt.Sprintf("Failed to reload jobs: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	// Reapply provisioning files
+	if err := app.applyProvisioning(); err != nil {
+		log.DefaultLogger.Error("Failed to apply provisioning", "error", err)
+		http.Error(w, fmt.Sprintf("Failed to apply provisioning: %v", err), http.StatusInternalServerError)
+		return
+	}
+
 	// Reschedule all jobs
 	app.mu.Lock()
 	// First, clear all existing schedules
@@ -1050,6 +1688,7 @@ func (app *App) handleReload(w http.ResponseWriter, r *http.Request) {
 		app.scheduler.Remove(entryID)
 		delete(app.cronIDs, jobID)
 	}
+	metricJobsScheduled.Set(0)
 	app.mu.Unlock()
 
 	// Then, schedule all loaded jobs
@@ -2,9 +2,38 @@ package plugin
 
 import (
 	"encoding/json"
+	"path/filepath"
 	"testing"
+
+	"github.com/robfig/cron/v3"
 )
 
+// newTestApp creates an App backed by a temporary data directory
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	dir := t.TempDir()
+	app := &App{
+		scheduler:  cron.New(),
+		jobs:       make(map[string]Job),
+		cronIDs:    make(map[string]cron.EntryID),
+		runs:       make(map[string]*activeRun),
+		dataDir:    dir,
+		jobsFile:   filepath.Join(dir, "jobs.json"),
+		configFile: filepath.Join(dir, "config.json"),
+		runsFile:   filepath.Join(dir, "runs.json"),
+
+		scheduleFile:    filepath.Join(dir, "schedule.json"),
+		calendars:       make(map[string]Calendar),
+		calendarsFile:   filepath.Join(dir, "calendars.json"),
+		assetsDir:       filepath.Join(dir, "assets"),
+		archiveDir:      filepath.Join(dir, "archive"),
+		usedLinksFile:   filepath.Join(dir, "used-links.json"),
+		provisioningDir: filepath.Join(dir, "provisioning"),
+	}
+	t.Cleanup(func() { app.scheduler.Stop() })
+	return app
+}
+
 func TestJobValidation(t *testing.T) {
 	tests := []struct {
 		name    string
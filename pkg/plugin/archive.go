@@ -0,0 +1,314 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// linksExpiryFile is the file of a run's archive directory holding when the last download link to
+// the run's files expires. Its name cannot be that of a report file.
+const linksExpiryFile = ".links-expire"
+
+// ArchivedFile is a report file kept in the archive
+type ArchivedFile struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ArchivedRun is a run whose report files are kept in the archive
+type ArchivedRun struct {
+	RunID     string         `json:"runId"`
+	Date      string         `json:"date"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Size      int64          `json:"size"`
+	Files     []ArchivedFile `json:"files"`
+	// When the last download link to the files expires, for runs whose files were sent as links
+	LinksExpireAt *time.Time `json:"linksExpireAt,omitempty"`
+
+	dir string
+}
+
+// archiveDirName turns a job ID into a safe directory name. Characters other than letters, digits,
+// '-' and '.' are written as '_' and their hex bytes, as are the dots of IDs made only of dots, so
+// that two job IDs never share a directory.
+func archiveDirName(jobID string) string {
+	if jobID == "" {
+		return "_"
+	}
+	onlyDots := strings.Trim(jobID, ".") == ""
+	var name strings.Builder
+	for _, b := range []byte(jobID) {
+		if b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '-' || b == '.' && !onlyDots {
+			name.WriteByte(b)
+		} else {
+			fmt.Fprintf(&name, "_%02x", b)
+		}
+	}
+	return name.String()
+}
+
+// storeReport keeps a report file in the archive, at <job>/<date>/<run>/<name>. When the run
+// already stored a file with that name, such as the document of another recipient, a number is
+// added to the name. It returns the name the file was stored as.
+func (app *App) storeReport(jobID, runID, name string, data []byte, at time.Time) (string, error) {
+	if !assetNamePattern.MatchString(runID) || !assetNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid report name %s/%s", runID, name)
+	}
+	dir := filepath.Join(app.archiveDir, archiveDirName(jobID), at.UTC().Format("2006-01-02"), runID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	ext := filepath.Ext(name)
+	stored := name
+	for i := 2; ; i++ {
+		file, err := os.OpenFile(filepath.Join(dir, stored), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			stored = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext)
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create report file: %w", err)
+		}
+		_, err = file.Write(data)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(file.Name())
+			return "", fmt.Errorf("failed to write report file: %w", err)
+		}
+		return stored, nil
+	}
+}
+
+// findReport returns the path of a file the run stored in the archive
+func (app *App) findReport(runID, name string) (string, error) {
+	if !assetNamePattern.MatchString(runID) || !assetNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid report name %s/%s", runID, name)
+	}
+	matches, err := filepath.Glob(filepath.Join(app.archiveDir, "*", "*", runID, name))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("report %s/%s not found", runID, name)
+	}
+	return matches[0], nil
+}
+
+// archiveRunID returns the ID of the run files are archived under, that of the run in progress or,
+// outside of a run, a new one
+func archiveRunID(ctx context.Context) string {
+	if runID := runIDFromContext(ctx); runID != "" {
+		return runID
+	}
+	return fmt.Sprintf("run-%d", time.Now().UnixNano())
+}
+
+// serveReport sends an archived report file as a download
+func serveReport(w http.ResponseWriter, r *http.Request, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read report: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	name := filepath.Base(path)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, name, info.ModTime(), file)
+}
+
+// findRunDir returns the archive directory of a run
+func (app *App) findRunDir(runID string) (string, error) {
+	if !assetNamePattern.MatchString(runID) {
+		return "", fmt.Errorf("invalid run ID %s", runID)
+	}
+	matches, err := filepath.Glob(filepath.Join(app.archiveDir, "*", "*", runID))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("run %s has no archived reports: %w", runID, os.ErrNotExist)
+	}
+	return matches[0], nil
+}
+
+// runFiles lists the files the run stored in the archive, along with the directory of its job
+func (app *App) runFiles(runID string) (string, []ArchivedFile, error) {
+	dir, err := app.findRunDir(runID)
+	if err != nil {
+		return "", nil, err
+	}
+	files, err := readRunDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	return filepath.Base(filepath.Dir(filepath.Dir(dir))), files, nil
+}
+
+// extendLinksExpiry records that download links to the files of a run are valid until expires, so
+// that the files are kept as long as one of the links can be used
+func (app *App) extendLinksExpiry(runID string, expires time.Time) error {
+	dir, err := app.findRunDir(runID)
+	if err != nil {
+		return err
+	}
+
+	app.archiveMu.Lock()
+	defer app.archiveMu.Unlock()
+
+	if current := readLinksExpiry(dir); current != nil && !current.Before(expires) {
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(dir, linksExpiryFile), []byte(strconv.FormatInt(expires.Unix(), 10)), 0644); err != nil {
+		return fmt.Errorf("failed to record download link expiry: %w", err)
+	}
+	return nil
+}
+
+// readLinksExpiry returns when the last download link to the files of a run expires, or nil if
+// the files were not sent as links
+func readLinksExpiry(dir string) *time.Time {
+	data, err := os.ReadFile(filepath.Join(dir, linksExpiryFile))
+	if err != nil {
+		return nil
+	}
+	unix, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return nil
+	}
+	expires := time.Unix(unix, 0).UTC()
+	return &expires
+}
+
+// readRunDir lists the files in the archive directory of a run
+func readRunDir(dir string) ([]ArchivedFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+	files := make([]ArchivedFile, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.Type().IsRegular() || !assetNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		files = append(files, ArchivedFile{Name: entry.Name(), Size: info.Size(), CreatedAt: info.ModTime().UTC()})
+	}
+	return files, nil
+}
+
+// archivedRuns lists the runs archived in a job directory, most recent first. A run is as old as
+// its most recent file.
+func (app *App) archivedRuns(jobDir string) ([]ArchivedRun, error) {
+	dirs, err := filepath.Glob(filepath.Join(app.archiveDir, jobDir, "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]ArchivedRun, 0, len(dirs))
+	for _, dir := range dirs {
+		files, err := readRunDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		run := ArchivedRun{RunID: filepath.Base(dir), Date: filepath.Base(filepath.Dir(dir)), Files: files, LinksExpireAt: readLinksExpiry(dir), dir: dir}
+		for _, file := range files {
+			run.Size += file.Size
+			if file.CreatedAt.After(run.CreatedAt) {
+				run.CreatedAt = file.CreatedAt
+			}
+		}
+		runs = append(runs, run)
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].CreatedAt.Equal(runs[j].CreatedAt) {
+			return runs[i].CreatedAt.After(runs[j].CreatedAt)
+		}
+		return runs[i].RunID > runs[j].RunID
+	})
+	return runs, nil
+}
+
+// canManageArchive reports whether the user manages the jobs whose reports are archived in the
+// job directory; reports of jobs that no longer exist are managed by admins
+func (app *App) canManageArchive(user *backend.User, jobDir string) bool {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+
+	found := false
+	for id, job := range app.jobs {
+		if archiveDirName(id) != jobDir {
+			continue
+		}
+		if !canManageJob(user, job) {
+			return false
+		}
+		found = true
+	}
+	return found || hasRole(user, roleAdmin)
+}
+
+// handleJobArchive lists the runs of a job kept in the archive with GET /jobs/{id}/archive, and
+// downloads one of their files with GET /jobs/{id}/archive/{runId}/{name}
+func (app *App) handleJobArchive(w http.ResponseWriter, r *http.Request, jobID, filePath string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	app.mu.RLock()
+	job, ok := app.jobs[jobID]
+	app.mu.RUnlock()
+
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if !canManageJob(requestUser(r), job) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if filePath == "" {
+		runs, err := app.archivedRuns(archiveDirName(jobID))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to list archived reports: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(runs)
+		return
+	}
+
+	runID, name, _ := strings.Cut(filePath, "/")
+	if !assetNamePattern.MatchString(runID) || !assetNamePattern.MatchString(name) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	matches, err := filepath.Glob(filepath.Join(app.archiveDir, archiveDirName(jobID), "*", runID, name))
+	if err != nil || len(matches) == 0 {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	serveReport(w, r, matches[0])
+}
@@ -0,0 +1,140 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestArchiveDirName(t *testing.T) {
+	tests := map[string]string{
+		"daily-ops":    "daily-ops",
+		"team/weekly":  "team_2fweekly",
+		"team_weekly":  "team_5fweekly",
+		"team weekly":  "team_20weekly",
+		"..":           "_2e_2e",
+		"":             "_",
+		"ventes été":   "ventes_20_c3_a9t_c3_a9",
+		"report.v2_fr": "report.v2_5ffr",
+	}
+	for id, want := range tests {
+		if got := archiveDirName(id); got != want {
+			t.Errorf("archiveDirName(%q) = %q, want %q", id, got, want)
+		}
+		if got := archiveDirName(id); filepath.Base(got) != got || got == ".." {
+			t.Errorf("archiveDirName(%q) = %q is not a single safe directory", id, got)
+		}
+	}
+}
+
+func TestStoreReport(t *testing.T) {
+	app := newTestApp(t)
+	at := time.Date(2026, 10, 16, 23, 30, 0, 0, time.FixedZone("UTC+2", 2*3600))
+
+	name, err := app.storeReport("team/weekly", "run-1", "report.pdf", []byte("alice"), at)
+	if err != nil || name != "report.pdf" {
+		t.Fatalf("storeReport() = %q, %v", name, err)
+	}
+	// Another recipient's document of the same run gets a numbered name
+	name, err = app.storeReport("team/weekly", "run-1", "report.pdf", []byte("bob"), at)
+	if err != nil || name != "report-2.pdf" {
+		t.Fatalf("storeReport() = %q, %v", name, err)
+	}
+
+	// Files are organised by job, UTC date and run
+	path, err := app.findReport("run-1", "report-2.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(app.archiveDir, "team_2fweekly", "2026-10-16", "run-1", "report-2.pdf"); path != want {
+		t.Errorf("Expected %s, got %s", want, path)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "bob" {
+		t.Errorf("Unexpected content %q", data)
+	}
+
+	if _, err := app.findReport("run-2", "report.pdf"); err == nil {
+		t.Error("Expected a missing report not to be found")
+	}
+	if _, err := app.storeReport("ops", "run-1", "../report.pdf", nil, at); err == nil {
+		t.Error("Expected an unsafe name to be rejected")
+	}
+}
+
+func TestJobArchivesAreSeparate(t *testing.T) {
+	app := newTestApp(t)
+	app.jobs["team weekly"] = Job{ID: "team weekly", Cron: "0 9 * * *", Owner: "alice"}
+	app.jobs["team_weekly"] = Job{ID: "team_weekly", Cron: "0 9 * * *", Owner: "bob"}
+	storeTestRun(t, app, "team weekly", "run-1", time.Now())
+
+	bob := &backend.User{Login: "bob", Role: roleEditor}
+	resp := callResource(t, app, bob, http.MethodGet, "/jobs/team_weekly/archive", "")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+	var runs []ArchivedRun
+	json.Unmarshal(resp.Body, &runs)
+	if len(runs) != 0 {
+		t.Errorf("Expected no runs of another job, got %+v", runs)
+	}
+	if resp := callResource(t, app, bob, http.MethodGet, "/jobs/team_weekly/archive/run-1/report.pdf", ""); resp.Status != http.StatusNotFound {
+		t.Errorf("Expected another job's report not to be found, got %d", resp.Status)
+	}
+	if app.canManageArchive(bob, archiveDirName("team weekly")) {
+		t.Error("Expected the other job's archive not to be managed by its owner")
+	}
+}
+
+func TestJobArchiveAPI(t *testing.T) {
+	app := newTestApp(t)
+	app.jobs["ops"] = Job{ID: "ops", Cron: "0 9 * * *", Owner: "editor"}
+	editor := &backend.User{Login: "editor", Role: roleEditor}
+	now := time.Now()
+	storeTestRun(t, app, "ops", "run-1", now.Add(-48*time.Hour))
+	storeTestRun(t, app, "ops", "run-2", now.Add(-time.Hour))
+	app.storeReport("ops", "run-2", "report.png", []byte("image"), now.Add(-time.Hour))
+	storeTestRun(t, app, "sales", "run-3", now)
+
+	resp := callResource(t, app, editor, http.MethodGet, "/jobs/ops/archive", "")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+	var runs []ArchivedRun
+	json.Unmarshal(resp.Body, &runs)
+	if len(runs) != 2 || runs[0].RunID != "run-2" || len(runs[0].Files) != 2 || runs[0].Size != 9 {
+		t.Fatalf("Expected the job's runs, most recent first, got %+v", runs)
+	}
+	if runs[1].Date != now.Add(-48*time.Hour).UTC().Format("2006-01-02") {
+		t.Errorf("Unexpected date %s", runs[1].Date)
+	}
+
+	resp = callResource(t, app, editor, http.MethodGet, "/jobs/ops/archive/run-2/report.png", "")
+	if resp.Status != http.StatusOK || string(resp.Body) != "image" {
+		t.Errorf("Expected the file, got %d: %s", resp.Status, resp.Body)
+	}
+
+	tests := []struct {
+		name   string
+		user   *backend.User
+		method string
+		path   string
+		status int
+	}{
+		{name: "file of another job", user: editor, method: http.MethodGet, path: "/jobs/ops/archive/run-3/report.pdf", status: http.StatusNotFound},
+		{name: "unknown job", user: editor, method: http.MethodGet, path: "/jobs/other/archive", status: http.StatusNotFound},
+		{name: "other editor", user: &backend.User{Login: "other", Role: roleEditor}, method: http.MethodGet, path: "/jobs/ops/archive", status: http.StatusForbidden},
+		{name: "delete", user: editor, method: http.MethodDelete, path: "/jobs/ops/archive", status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := callResource(t, app, tt.user, tt.method, tt.path, ""); resp.Status != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, resp.Status, resp.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// maxAssetSize bounds uploaded assets, which are embedded in every report using them
+const maxAssetSize = 2 << 20
+
+// assetNamePattern restricts asset names to safe file names
+var assetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// assetContentTypes are the accepted asset types, which PDF reports can embed
+var assetContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+}
+
+// Asset describes an uploaded file, such as a logo shown on PDF cover pages
+type Asset struct {
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	ModifiedAt  time.Time `json:"modifiedAt"`
+}
+
+// assetPath returns the path of the named asset in the assets directory
+func (app *App) assetPath(name string) (string, error) {
+	if !assetNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid asset name %q", name)
+	}
+	return filepath.Join(app.assetsDir, name), nil
+}
+
+// readAsset returns the content of the named asset
+func (app *App) readAsset(name string) ([]byte, error) {
+	path, err := app.assetPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("unknown asset %q", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset: %w", err)
+	}
+	return data, nil
+}
+
+// assetNames returns the names of the assets the job refers to
+func (job Job) assetNames() []string {
+	var names []string
+	if logo := job.pdfOptions().Logo; logo != "" {
+		names = append(names, logo)
+	}
+	if watermark := job.imageOptions().Watermark; watermark != nil && watermark.Logo != "" {
+		names = append(names, watermark.Logo)
+	}
+	return names
+}
+
+// checkJobAssets checks that the assets the job refers to exist
+func (app *App) checkJobAssets(job Job) error {
+	for _, name := range job.assetNames() {
+		path, err := app.assetPath(name)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("unknown asset %q", name)
+		}
+	}
+	return nil
+}
+
+// listAssets returns the uploaded assets, sorted by name
+func (app *App) listAssets() ([]Asset, error) {
+	entries, err := os.ReadDir(app.assetsDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Asset{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read assets directory: %w", err)
+	}
+
+	assets := make([]Asset, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.Type().IsRegular() || !assetNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		data, err := app.readAsset(entry.Name())
+		if err != nil {
+			continue
+		}
+		assets = append(assets, Asset{
+			Name:        entry.Name(),
+			ContentType: http.DetectContentType(data),
+			Size:        info.Size(),
+			ModifiedAt:  info.ModTime().UTC(),
+		})
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Name < assets[j].Name
+	})
+	return assets, nil
+}
+
+// handleAssets lists the uploaded assets
+func (app *App) handleAssets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	assets, err := app.listAssets()
+	if err != nil {
+		log.DefaultLogger.Error("Failed to list assets", "error", err)
+		http.Error(w, fmt.Sprintf("Failed to list assets: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assets)
+}
+
+// handleAssetByName downloads, uploads and deletes /assets/{name}
+func (app *App) handleAssetByName(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/assets/")
+	path, err := app.assetPath(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		data, err := app.readAsset(name)
+		if err != nil {
+			http.Error(w, "Asset not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", http.DetectContentType(data))
+		w.Write(data)
+	case http.MethodPut:
+		app.uploadAsset(w, r, name, path)
+	case http.MethodDelete:
+		app.deleteAsset(w, name, path)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// uploadAsset stores the request body as the named asset, replacing any previous version
+func (app *App) uploadAsset(w http.ResponseWriter, r *http.Request, name, path string) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxAssetSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxAssetSize {
+		http.Error(w, fmt.Sprintf("Asset exceeds %d bytes", maxAssetSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if !assetContentTypes[contentType] {
+		http.Error(w, fmt.Sprintf("Unsupported asset type %s (expected PNG or JPEG)", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	_, statErr := os.Stat(path)
+	if err := os.MkdirAll(app.assetsDir, 0755); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create assets directory: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.DefaultLogger.Error("Failed to save asset", "name", name, "error", err)
+		http.Error(w, fmt.Sprintf("Failed to save asset: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.DefaultLogger.Info("Saved asset", "name", name, "bytes", len(data))
+
+	status := http.StatusOK
+	if statErr != nil {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Asset{Name: name, ContentType: contentType, Size: int64(len(data)), ModifiedAt: time.Now().UTC()})
+}
+
+// deleteAsset deletes an asset that no job uses
+func (app *App) deleteAsset(w http.ResponseWriter, name, path string) {
+	app.mu.RLock()
+	var users []string
+	for _, job := range app.jobs {
+		for _, used := range job.assetNames() {
+			if used == name {
+				users = append(users, job.ID)
+				break
+			}
+		}
+	}
+	app.mu.RUnlock()
+
+	if len(users) > 0 {
+		sort.Strings(users)
+		http.Error(w, fmt.Sprintf("Asset is used by jobs: %s", strings.Join(users, ", ")), http.StatusConflict)
+		return
+	}
+
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.Error(w, "Asset not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to delete asset: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/color"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestAssetsAPI(t *testing.T) {
+	app := newTestApp(t)
+	admin := &backend.User{Login: "admin", Role: roleAdmin}
+	logo := string(testPNG(t, 20, 10, color.Black))
+
+	resp := callResource(t, app, admin, http.MethodPut, "/assets/logo.png", logo)
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("Expected upload to create the asset, got %d: %s", resp.Status, resp.Body)
+	}
+	if resp = callResource(t, app, admin, http.MethodPut, "/assets/logo.png", logo); resp.Status != http.StatusOK {
+		t.Errorf("Expected upload to replace the asset, got %d", resp.Status)
+	}
+	if resp = callResource(t, app, admin, http.MethodPut, "/assets/notes.txt", "hello"); resp.Status != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected text to be rejected, got %d", resp.Status)
+	}
+	if resp = callResource(t, app, admin, http.MethodPut, "/assets/.jobs.json", logo); resp.Status != http.StatusBadRequest {
+		t.Errorf("Expected unsafe name to be rejected, got %d", resp.Status)
+	}
+
+	resp = callResource(t, app, admin, http.MethodGet, "/assets", "")
+	var assets []Asset
+	json.Unmarshal(resp.Body, &assets)
+	if len(assets) != 1 || assets[0].Name != "logo.png" || assets[0].ContentType != "image/png" {
+		t.Errorf("Unexpected assets: %+v", assets)
+	}
+	resp = callResource(t, app, admin, http.MethodGet, "/assets/logo.png", "")
+	if resp.Status != http.StatusOK || !bytes.Equal(resp.Body, []byte(logo)) {
+		t.Errorf("Expected the uploaded logo, got %d", resp.Status)
+	}
+
+	resp = callResource(t, app, admin, http.MethodPost, "/jobs", `{"id": "board", "cron": "0 9 * * 1", "format": "pdf", "pdf": {"coverPage": true, "logo": "other.png"}}`)
+	if resp.Status != http.StatusBadRequest {
+		t.Errorf("Expected job with unknown logo to be rejected, got %d", resp.Status)
+	}
+	resp = callResource(t, app, admin, http.MethodPost, "/jobs", `{"id": "board", "cron": "0 9 * * 1", "format": "pdf", "pdf": {"coverPage": true, "logo": "logo.png"}}`)
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("Expected job to be created, got %d: %s", resp.Status, resp.Body)
+	}
+
+	if resp = callResource(t, app, admin, http.MethodDelete, "/assets/logo.png", ""); resp.Status != http.StatusConflict {
+		t.Errorf("Expected deleting a logo in use to fail, got %d", resp.Status)
+	}
+	callResource(t, app, admin, http.MethodDelete, "/jobs/board", "")
+	if resp = callResource(t, app, admin, http.MethodDelete, "/assets/logo.png", ""); resp.Status != http.StatusNoContent {
+		t.Errorf("Expected logo to be deleted, got %d", resp.Status)
+	}
+	if resp = callResource(t, app, admin, http.MethodGet, "/assets/logo.png", ""); resp.Status != http.StatusNotFound {
+		t.Errorf("Expected deleted logo to be gone, got %d", resp.Status)
+	}
+}
@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+)
+
+// Grafana organization roles, as sent in the plugin request context
+const (
+	roleViewer = "Viewer"
+	roleEditor = "Editor"
+	roleAdmin  = "Admin"
+)
+
+// routeRoles maps an HTTP method to the minimum role needed to call it; "*" applies to any other method
+type routeRoles map[string]string
+
+// roleLevel orders roles so that a higher level includes the permissions of the lower ones
+func roleLevel(role string) int {
+	switch role {
+	case roleAdmin:
+		return 3
+	case roleEditor:
+		return 2
+	case roleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// hasRole reports whether the user has at least the given role
+func hasRole(user *backend.User, role string) bool {
+	return user != nil && roleLevel(user.Role) >= roleLevel(role)
+}
+
+// requestUser returns the Grafana user that made the request, or nil if unknown
+func requestUser(r *http.Request) *backend.User {
+	return httpadapter.UserFromContext(r.Context())
+}
+
+// authorize wraps a handler so that it is only reachable by users with the role required for the request method
+func (app *App) authorize(roles routeRoles, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, ok := roles[r.Method]
+		if !ok {
+			role = roles["*"]
+		}
+		if !hasRole(requestUser(r), role) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// canManageJob reports whether the user may modify, delete or execute a job.
+// Admins manage every job; editors manage the jobs they own.
+func canManageJob(user *backend.User, job Job) bool {
+	if hasRole(user, roleAdmin) {
+		return true
+	}
+	return hasRole(user, roleEditor) && job.Owner != "" && job.Owner == user.Login
+}
@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+)
+
+// responseRecorder captures the response of a resource call
+type responseRecorder struct {
+	resp *backend.CallResourceResponse
+}
+
+func (r *responseRecorder) Send(resp *backend.CallResourceResponse) error {
+	r.resp = resp
+	return nil
+}
+
+// callResource sends a request through the resource router as the given Grafana user
+func callResource(t *testing.T, app *App, user *backend.User, method, path, body string) *backend.CallResourceResponse {
+	t.Helper()
+
+	recorder := &responseRecorder{}
+	handler := httpadapter.New(app.routes())
+	resourcePath, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "?")
+	err := handler.CallResource(context.Background(), &backend.CallResourceRequest{
+		Method: method,
+		Path:   resourcePath,
+		URL:    path,
+		Body:   []byte(body),
+		PluginContext: backend.PluginContext{
+			User: user,
+		},
+	}, recorder)
+	if err != nil {
+		t.Fatalf("CallResource failed: %v", err)
+	}
+	return recorder.resp
+}
+
+func TestRouteAuthorization(t *testing.T) {
+	admin := &backend.User{Login: "admin", Role: roleAdmin}
+	editor := &backend.User{Login: "editor", Role: roleEditor}
+	otherEditor := &backend.User{Login: "other", Role: roleEditor}
+	viewer := &backend.User{Login: "viewer", Role: roleViewer}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		allowed []*backend.User
+		denied  []*backend.User
+	}{
+		{name: "list jobs", method: http.MethodGet, path: "/jobs", allowed: []*backend.User{admin, editor, viewer}, denied: []*backend.User{nil}},
+		{name: "create job", method: http.MethodPost, path: "/jobs", body: `{"cron": "0 9 * * *"}`, allowed: []*backend.User{admin, editor}, denied: []*backend.User{viewer, nil}},
+		{name: "get job", method: http.MethodGet, path: "/jobs/owned", allowed: []*backend.User{admin, editor, viewer}, denied: []*backend.User{nil}},
+		{name: "update job", method: http.MethodPut, path: "/jobs/owned", body: `{"cron": "0 9 * * *"}`, allowed: []*backend.User{admin, editor}, denied: []*backend.User{otherEditor, viewer}},
+		{name: "update unowned job", method: http.MethodPut, path: "/jobs/unowned", body: `{"cron": "0 9 * * *"}`, allowed: []*backend.User{admin}, denied: []*backend.User{editor, viewer}},
+		{name: "delete job", method: http.MethodDelete, path: "/jobs/owned", allowed: []*backend.User{admin, editor}, denied: []*backend.User{otherEditor, viewer}},
+		{name: "execute job", method: http.MethodPost, path: "/jobs/owned/execute", allowed: []*backend.User{admin, editor}, denied: []*backend.User{otherEditor, viewer}},
+		{name: "subscribe", method: http.MethodPost, path: "/jobs/owned/subscribers", allowed: []*backend.User{admin, editor, otherEditor, viewer}, denied: []*backend.User{nil}},
+		{name: "unsubscribe", method: http.MethodDelete, path: "/jobs/owned/subscribers", allowed: []*backend.User{admin, editor, otherEditor, viewer}, denied: []*backend.User{nil}},
+		{name: "pdf passwords", method: http.MethodGet, path: "/jobs/owned/pdf-passwords", allowed: []*backend.User{admin, editor}, denied: []*backend.User{otherEditor, viewer}},
+		{name: "job archive", method: http.MethodGet, path: "/jobs/owned/archive", allowed: []*backend.User{admin, editor}, denied: []*backend.User{otherEditor, viewer}},
+		{name: "export jobs", method: http.MethodGet, path: "/jobs/export", allowed: []*backend.User{admin, editor}, denied: []*backend.User{viewer}},
+		{name: "import jobs", method: http.MethodPost, path: "/jobs/import", body: `{"version": 1, "jobs": []}`, allowed: []*backend.User{admin}, denied: []*backend.User{editor, viewer}},
+		{name: "get config", method: http.MethodGet, path: "/config", allowed: []*backend.User{admin}, denied: []*backend.User{editor, viewer}},
+		{name: "update config", method: http.MethodPost, path: "/config", body: `{}`, allowed: []*backend.User{admin}, denied: []*backend.User{editor, viewer}},
+		{name: "test email", method: http.MethodPost, path: "/test-email", body: `{`, allowed: []*backend.User{admin, editor}, denied: []*backend.User{viewer}},
+		{name: "list calendars", method: http.MethodGet, path: "/calendars", allowed: []*backend.User{admin, editor, viewer}, denied: []*backend.User{nil}},
+		{name: "create calendar", method: http.MethodPost, path: "/calendars", body: `{"id": "ops"}`, allowed: []*backend.User{admin}, denied: []*backend.User{editor, viewer}},
+		{name: "import calendar", method: http.MethodPost, path: "/calendars/ops/import", body: "BEGIN:VCALENDAR\nEND:VCALENDAR", allowed: []*backend.User{admin}, denied: []*backend.User{editor, viewer}},
+		{name: "list assets", method: http.MethodGet, path: "/assets", allowed: []*backend.User{admin, editor, viewer}, denied: []*backend.User{nil}},
+		{name: "upload asset", method: http.MethodPut, path: "/assets/logo.png", body: "\x89PNG\r\n\x1a\n", allowed: []*backend.User{admin}, denied: []*backend.User{editor, viewer}},
+		{name: "reports", method: http.MethodDelete, path: "/reports/run-1/report.pdf", allowed: []*backend.User{admin, editor, viewer}, denied: []*backend.User{nil}},
+		{name: "run files", method: http.MethodGet, path: "/runs/run-1/files", allowed: []*backend.User{admin, editor}, denied: []*backend.User{viewer, nil}},
+		{name: "run links", method: http.MethodPost, path: "/runs/run-1/links", allowed: []*backend.User{admin, editor}, denied: []*backend.User{viewer, nil}},
+		{name: "cron preview", method: http.MethodGet, path: "/cron/preview?expr=0+9+*+*+*", allowed: []*backend.User{admin, editor, viewer}, denied: []*backend.User{nil}},
+		{name: "dashboards", method: http.MethodGet, path: "/dashboards", allowed: []*backend.User{admin, editor, viewer}, denied: []*backend.User{nil}},
+		{name: "version", method: http.MethodGet, path: "/version", allowed: []*backend.User{admin, editor, viewer}, denied: []*backend.User{nil}},
+		{name: "reload", method: http.MethodPost, path: "/reload", allowed: []*backend.User{admin}, denied: []*backend.User{editor, viewer}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := func(user *backend.User, wantForbidden bool) {
+				app := newTestApp(t)
+				app.jobs["owned"] = Job{ID: "owned", Cron: "0 9 * * *", Owner: "editor"}
+				app.jobs["unowned"] = Job{ID: "unowned", Cron: "0 9 * * *"}
+
+				resp := callResource(t, app, user, tt.method, tt.path, tt.body)
+				forbidden := resp.Status == http.StatusForbidden
+				if forbidden != wantForbidden {
+					login := "anonymous"
+					if user != nil {
+						login = user.Login
+					}
+					t.Errorf("%s %s as %s: got status %d, want forbidden=%v", tt.method, tt.path, login, resp.Status, wantForbidden)
+				}
+			}
+			for _, user := range tt.allowed {
+				check(user, false)
+			}
+			for _, user := range tt.denied {
+				check(user, true)
+			}
+		})
+	}
+}
+
+func TestCreateJobRecordsOwner(t *testing.T) {
+	app := newTestApp(t)
+	editor := &backend.User{Login: "editor", Role: roleEditor}
+
+	resp := callResource(t, app, editor, http.MethodPost, "/jobs", `{"id": "mine", "cron": "0 9 * * *", "owner": "someone-else"}`)
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", resp.Status, resp.Body)
+	}
+	if owner := app.jobs["mine"].Owner; owner != "editor" {
+		t.Errorf("Expected owner editor, got %s", owner)
+	}
+
+	// Updating keeps the original owner
+	resp = callResource(t, app, editor, http.MethodPut, "/jobs/mine", `{"cron": "0 10 * * *", "owner": "someone-else"}`)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+	if owner := app.jobs["mine"].Owner; owner != "editor" {
+		t.Errorf("Expected owner to be kept, got %s", owner)
+	}
+}
@@ -0,0 +1,344 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"gopkg.in/yaml.v3"
+)
+
+// bundleVersion is the current version of the job bundle format
+const bundleVersion = 1
+
+// Import conflict strategies, applied when an imported job ID already exists
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictRename    = "rename"
+)
+
+// JobBundle is a portable, versioned collection of jobs used to move reports between Grafana instances
+type JobBundle struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Jobs       []Job     `json:"jobs"`
+}
+
+// ImportResult describes what happened (or would happen, in dry-run mode) to a single imported job
+type ImportResult struct {
+	ID           string `json:"id"`
+	OriginalID   string `json:"originalId"`
+	DashboardUID string `json:"dashboardUid"`
+	Action       string `json:"action"` // created, overwritten, skipped, renamed or invalid
+	Error        string `json:"error,omitempty"`
+}
+
+// handleExportJobs returns the selected jobs (or all jobs) as a JSON or YAML bundle
+func (app *App) handleExportJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Collect requested IDs, supporting both ?ids=a,b and ?id=a&id=b
+	var ids []string
+	for _, value := range r.URL.Query()["ids"] {
+		ids = append(ids, strings.Split(value, ",")...)
+	}
+	ids = append(ids, r.URL.Query()["id"]...)
+
+	bundle := JobBundle{
+		Version:    bundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Jobs:       []Job{},
+	}
+
+	user := requestUser(r)
+	app.mu.RLock()
+	if len(ids) == 0 {
+		for _, job := range app.jobs {
+			bundle.Jobs = append(bundle.Jobs, job.visibleTo(user).redacted())
+		}
+	} else {
+		for _, id := range ids {
+			id = strings.TrimSpace(id)
+			if id == "" {
+				continue
+			}
+			job, ok := app.jobs[id]
+			if !ok {
+				app.mu.RUnlock()
+				http.Error(w, fmt.Sprintf("Job not found: %s", id), http.StatusNotFound)
+				return
+			}
+			bundle.Jobs = append(bundle.Jobs, job.visibleTo(user).redacted())
+		}
+	}
+	app.mu.RUnlock()
+
+	// Keep exports stable so bundles can be diffed and committed
+	sort.Slice(bundle.Jobs, func(i, j int) bool {
+		return bundle.Jobs[i].ID < bundle.Jobs[j].ID
+	})
+
+	if wantsYAML(r.URL.Query().Get("format"), r.Header.Get("Accept")) {
+		data, err := marshalBundleYAML(bundle)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to encode bundle: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(data)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bundle)
+}
+
+// handleImportJobs applies a bundle to this instance
+//
+// Query parameters:
+//   - strategy: skip (default), overwrite or rename, used when a job ID already exists
+//   - dryRun: when true, report the planned actions without changing anything
+//   - remap: oldUid:newUid pairs (repeatable or comma separated) to rewrite dashboard UIDs
+func (app *App) handleImportJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	strategy := query.Get("strategy")
+	if strategy == "" {
+		strategy = conflictSkip
+	}
+	if strategy != conflictSkip && strategy != conflictOverwrite && strategy != conflictRename {
+		http.Error(w, fmt.Sprintf("Invalid conflict strategy: %s", strategy), http.StatusBadRequest)
+		return
+	}
+
+	dryRun := query.Get("dryRun") == "true" || query.Get("dryRun") == "1"
+
+	remap, err := parseUIDRemap(query["remap"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	bundle, err := parseBundle(data, r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid bundle: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	owner := ""
+	if user := requestUser(r); user != nil {
+		owner = user.Login
+	}
+
+	results, err := app.importBundle(bundle, strategy, remap, owner, dryRun)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to import bundle: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dryRun":   dryRun,
+		"strategy": strategy,
+		"results":  results,
+	})
+}
+
+// importBundle plans and, unless dryRun is set, applies the jobs of a bundle.
+// Imported jobs are owned by the importing user, since owners rarely exist on the target instance.
+func (app *App) importBundle(bundle *JobBundle, strategy string, remap map[string]string, owner string, dryRun bool) ([]ImportResult, error) {
+	results := make([]ImportResult, 0, len(bundle.Jobs))
+	var toApply []Job
+
+	app.mu.RLock()
+	// Track IDs taken by this import too, so two bundle entries never collide
+	taken := make(map[string]bool, len(app.jobs))
+	provisioned := make(map[string]bool)
+	for id, job := range app.jobs {
+		taken[id] = true
+		provisioned[id] = job.Provisioned
+	}
+	app.mu.RUnlock()
+
+	for _, job := range bundle.Jobs {
+		result := ImportResult{
+			ID:         job.ID,
+			OriginalID: job.ID,
+		}
+
+		if newUID, ok := remap[job.DashboardUID]; ok {
+			job.DashboardUID = newUID
+		}
+		result.DashboardUID = job.DashboardUID
+
+		if job.ID == "" {
+			job.ID = fmt.Sprintf("job-%d", time.Now().UnixNano())
+			result.ID = job.ID
+		}
+
+		// Imported jobs are managed through the API, even if they were provisioned on the source instance
+		job.Provisioned = false
+		job.Owner = owner
+
+		if err := app.validateJob(job); err != nil {
+			result.Action = "invalid"
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		if err := job.validateRunAt(nil, time.Now()); err != nil {
+			result.Action = "invalid"
+			result.Error = fmt.Sprintf("invalid schedule: %v", err)
+			results = append(results, result)
+			continue
+		}
+
+		switch {
+		case !taken[job.ID]:
+			result.Action = "created"
+		case strategy == conflictOverwrite && provisioned[job.ID]:
+			result.Action = "skipped"
+			result.Error = "Job is provisioned and cannot be modified"
+			results = append(results, result)
+			continue
+		case strategy == conflictOverwrite:
+			result.Action = "overwritten"
+		case strategy == conflictRename:
+			job.ID = uniqueJobID(job.ID, taken)
+			result.ID = job.ID
+			result.Action = "renamed"
+		default:
+			result.Action = "skipped"
+			results = append(results, result)
+			continue
+		}
+
+		taken[job.ID] = true
+		toApply = append(toApply, job)
+		results = append(results, result)
+	}
+
+	if dryRun || len(toApply) == 0 {
+		return results, nil
+	}
+
+	app.mu.Lock()
+	for _, job := range toApply {
+		app.jobs[job.ID] = job
+	}
+	app.mu.Unlock()
+
+	for _, job := range toApply {
+		if err := app.scheduleJob(job); err != nil {
+			log.DefaultLogger.Error("Failed to schedule imported job", "id", job.ID, "error", err)
+		}
+	}
+
+	if err := app.saveJobs(); err != nil {
+		return results, err
+	}
+
+	log.DefaultLogger.Info("Imported jobs", "count", len(toApply), "strategy", strategy)
+	return results, nil
+}
+
+// uniqueJobID returns the first "<id>-<n>" that is not already taken
+func uniqueJobID(id string, taken map[string]bool) string {
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", id, i)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// parseUIDRemap parses oldUid:newUid pairs into a lookup map
+func parseUIDRemap(values []string) (map[string]string, error) {
+	remap := make(map[string]string)
+	for _, value := range values {
+		for _, pair := range strings.Split(value, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			parts := strings.SplitN(pair, ":", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return nil, fmt.Errorf("invalid remap entry %q, expected oldUid:newUid", pair)
+			}
+			remap[parts[0]] = parts[1]
+		}
+	}
+	return remap, nil
+}
+
+// parseBundle decodes a JSON or YAML bundle and checks its version
+func parseBundle(data []byte, contentType string) (*JobBundle, error) {
+	var bundle JobBundle
+
+	if strings.Contains(contentType, "yaml") {
+		// Convert YAML to JSON so the Job JSON tags and backward compatibility handling apply
+		var raw interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		converted, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+	}
+
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, err
+	}
+
+	if bundle.Version == 0 {
+		return nil, fmt.Errorf("missing bundle version")
+	}
+	if bundle.Version > bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d (max %d)", bundle.Version, bundleVersion)
+	}
+
+	return &bundle, nil
+}
+
+// marshalBundleYAML encodes a bundle as YAML using the same field names as the JSON format
+func marshalBundleYAML(bundle JobBundle) ([]byte, error) {
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(raw)
+}
+
+// wantsYAML reports whether the client asked for a YAML response
+func wantsYAML(format, accept string) bool {
+	if format != "" {
+		return format == "yaml" || format == "yml"
+	}
+	return strings.Contains(accept, "yaml")
+}
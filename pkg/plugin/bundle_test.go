@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	source := newTestApp(t)
+	source.jobs["daily"] = Job{ID: "daily", Cron: "0 9 * * *", DashboardUID: "staging-uid", Format: "png"}
+	source.jobs["weekly"] = Job{ID: "weekly", Cron: "0 9 * * 1", DashboardUID: "other-uid", Format: "pdf"}
+
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			source.handleExportJobs(rec, httptest.NewRequest(http.MethodGet, "/jobs/export?ids=daily&format="+format, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			target := newTestApp(t)
+			req := httptest.NewRequest(http.MethodPost, "/jobs/import?remap=staging-uid:prod-uid", strings.NewReader(rec.Body.String()))
+			req.Header.Set("Content-Type", "application/"+format)
+			rec = httptest.NewRecorder()
+			target.handleImportJobs(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			job, ok := target.jobs["daily"]
+			if !ok {
+				t.Fatal("Expected job daily to be imported")
+			}
+			if job.DashboardUID != "prod-uid" {
+				t.Errorf("Expected remapped dashboard UID prod-uid, got %s", job.DashboardUID)
+			}
+			if _, ok := target.jobs["weekly"]; ok {
+				t.Error("Expected only the selected job to be exported")
+			}
+			if _, ok := target.cronIDs["daily"]; !ok {
+				t.Error("Expected imported job to be scheduled")
+			}
+		})
+	}
+}
+
+func TestImportConflictStrategies(t *testing.T) {
+	bundle := &JobBundle{
+		Version: bundleVersion,
+		Jobs:    []Job{{ID: "daily", Cron: "0 10 * * *", DashboardUID: "new"}},
+	}
+
+	tests := []struct {
+		strategy   string
+		dryRun     bool
+		wantAction string
+		wantID     string
+		wantUID    string
+		wantCount  int
+	}{
+		{strategy: conflictSkip, wantAction: "skipped", wantID: "daily", wantUID: "old", wantCount: 1},
+		{strategy: conflictOverwrite, wantAction: "overwritten", wantID: "daily", wantUID: "new", wantCount: 1},
+		{strategy: conflictRename, wantAction: "renamed", wantID: "daily-1", wantUID: "old", wantCount: 2},
+		{strategy: conflictOverwrite, dryRun: true, wantAction: "overwritten", wantID: "daily", wantUID: "old", wantCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			app := newTestApp(t)
+			app.jobs["daily"] = Job{ID: "daily", Cron: "0 9 * * *", DashboardUID: "old"}
+
+			results, err := app.importBundle(bundle, tt.strategy, nil, "admin", tt.dryRun)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(results) != 1 || results[0].Action != tt.wantAction || results[0].ID != tt.wantID {
+				t.Fatalf("Unexpected results: %+v", results)
+			}
+			if app.jobs["daily"].DashboardUID != tt.wantUID {
+				t.Errorf("Expected existing job UID %s, got %s", tt.wantUID, app.jobs["daily"].DashboardUID)
+			}
+			if len(app.jobs) != tt.wantCount {
+				t.Errorf("Expected %d jobs, got %d", tt.wantCount, len(app.jobs))
+			}
+		})
+	}
+}
+
+func TestParseBundleRejectsUnknownVersion(t *testing.T) {
+	data, _ := json.Marshal(JobBundle{Version: bundleVersion + 1})
+	if _, err := parseBundle(data, "application/json"); err == nil {
+		t.Error("Expected error for unsupported bundle version")
+	}
+	if _, err := parseBundle([]byte(`{"jobs": []}`), "application/json"); err == nil {
+		t.Error("Expected error for missing bundle version")
+	}
+}
@@ -0,0 +1,539 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/robfig/cron/v3"
+)
+
+// Calendar policies, deciding what happens to occurrences falling on days the job's calendar excludes
+const (
+	calendarSkip            = "skip"            // do not run (default)
+	calendarNextBusinessDay = "nextBusinessDay" // run at the same time on the next business day
+)
+
+// calendarLookahead bounds the search for a business day, so a calendar excluding every day cannot hang the scheduler
+const calendarLookahead = 366
+
+// dateLayout is the format of holiday dates
+const dateLayout = "2006-01-02"
+
+// Calendar is a named set of business days: the weekdays it works on, minus its holidays
+type Calendar struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name,omitempty"`
+	Workdays []string  `json:"workdays,omitempty"` // mon, tue, wed, thu, fri, sat or sun; Monday to Friday when empty
+	Holidays []Holiday `json:"holidays,omitempty"`
+}
+
+// Holiday is a day excluded by a calendar
+type Holiday struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	Name string `json:"name,omitempty"`
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// businessCalendar is a validated calendar, ready to answer whether a day is a business day
+type businessCalendar struct {
+	workdays [7]bool
+	holidays map[string]bool
+}
+
+// compile validates the calendar and returns its business day lookup
+func (c Calendar) compile() (*businessCalendar, error) {
+	bc := &businessCalendar{holidays: make(map[string]bool, len(c.Holidays))}
+
+	workdays := c.Workdays
+	if len(workdays) == 0 {
+		workdays = []string{"mon", "tue", "wed", "thu", "fri"}
+	}
+	for _, name := range workdays {
+		day, ok := weekdayNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("invalid workday %q", name)
+		}
+		bc.workdays[day] = true
+	}
+
+	for _, holiday := range c.Holidays {
+		if _, err := time.Parse(dateLayout, holiday.Date); err != nil {
+			return nil, fmt.Errorf("invalid holiday date %q (expected YYYY-MM-DD)", holiday.Date)
+		}
+		bc.holidays[holiday.Date] = true
+	}
+
+	return bc, nil
+}
+
+// isBusinessDay reports whether the day of t, in t's time zone, is a business day
+func (bc *businessCalendar) isBusinessDay(t time.Time) bool {
+	return bc.workdays[t.Weekday()] && !bc.holidays[t.Format(dateLayout)]
+}
+
+// calendarSchedule applies a business calendar to a schedule
+type calendarSchedule struct {
+	schedule cron.Schedule
+	calendar *businessCalendar
+	policy   string
+}
+
+// Next returns the next occurrence on a business day. With the next business day policy, an occurrence
+// on an excluded day is moved to the same time on the following business day, where it is merged with
+// that day's own occurrence, if any.
+func (s calendarSchedule) Next(t time.Time) time.Time {
+	limit := t.AddDate(0, 0, calendarLookahead)
+
+	if s.policy != calendarNextBusinessDay {
+		next := s.schedule.Next(t)
+		for !next.IsZero() && !s.calendar.isBusinessDay(next) {
+			if next.After(limit) {
+				return time.Time{}
+			}
+			next = s.schedule.Next(next)
+		}
+		return next
+	}
+
+	// Occurrences on the excluded days just before t may be shifted after t, so start from the first of
+	// these days. Shifting preserves order, so the first shifted occurrence after t is the next one.
+	from := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i < calendarLookahead && !s.calendar.isBusinessDay(from.AddDate(0, 0, -1)); i++ {
+		from = from.AddDate(0, 0, -1)
+	}
+	for next := s.schedule.Next(from.Add(-time.Second)); !next.IsZero() && !next.After(limit); next = s.schedule.Next(next) {
+		if shifted := s.shift(next); shifted.After(t) {
+			return shifted
+		}
+	}
+	return time.Time{}
+}
+
+// shift moves t to the same time on the first business day from t's day
+func (s calendarSchedule) shift(t time.Time) time.Time {
+	for i := 0; i < calendarLookahead; i++ {
+		if s.calendar.isBusinessDay(t) {
+			return t
+		}
+		t = t.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
+
+// validateCalendarPolicy checks the job's calendar policy
+func (job Job) validateCalendarPolicy() error {
+	switch job.CalendarPolicy {
+	case "", calendarSkip, calendarNextBusinessDay:
+		return nil
+	default:
+		return fmt.Errorf("invalid calendar policy %q (expected %s or %s)", job.CalendarPolicy, calendarSkip, calendarNextBusinessDay)
+	}
+}
+
+// jobSchedule returns the schedule the scheduler follows for the job: its own schedule, restricted by its calendar
+func (app *App) jobSchedule(job Job) (cron.Schedule, error) {
+	schedule, err := job.schedule()
+	if err != nil || job.Calendar == "" {
+		return schedule, err
+	}
+
+	app.calendarsMu.RLock()
+	calendar, ok := app.calendars[job.Calendar]
+	app.calendarsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown calendar %q", job.Calendar)
+	}
+
+	bc, err := calendar.compile()
+	if err != nil {
+		return nil, fmt.Errorf("calendar %s: %w", calendar.ID, err)
+	}
+	return calendarSchedule{schedule: schedule, calendar: bc, policy: job.CalendarPolicy}, nil
+}
+
+// checkJobCalendar checks that the job's calendar exists
+func (app *App) checkJobCalendar(job Job) error {
+	if err := job.validateCalendarPolicy(); err != nil {
+		return err
+	}
+	if job.Calendar == "" {
+		return nil
+	}
+
+	app.calendarsMu.RLock()
+	_, ok := app.calendars[job.Calendar]
+	app.calendarsMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown calendar %q", job.Calendar)
+	}
+	return nil
+}
+
+// loadCalendars loads calendars from the JSON file
+func (app *App) loadCalendars() error {
+	app.calendarsMu.Lock()
+	defer app.calendarsMu.Unlock()
+
+	app.calendars = make(map[string]Calendar)
+
+	data, err := os.ReadFile(app.calendarsFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read calendars file: %w", err)
+	}
+
+	var calendars []Calendar
+	if err := json.Unmarshal(data, &calendars); err != nil {
+		return fmt.Errorf("failed to parse calendars file: %w", err)
+	}
+	for _, calendar := range calendars {
+		app.calendars[calendar.ID] = calendar
+	}
+	return nil
+}
+
+// saveCalendars saves calendars to the JSON file
+func (app *App) saveCalendars() error {
+	app.calendarsMu.RLock()
+	calendars := make([]Calendar, 0, len(app.calendars))
+	for _, calendar := range app.calendars {
+		calendars = append(calendars, calendar)
+	}
+	app.calendarsMu.RUnlock()
+
+	sort.Slice(calendars, func(i, j int) bool {
+		return calendars[i].ID < calendars[j].ID
+	})
+
+	data, err := json.MarshalIndent(calendars, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal calendars: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(app.calendarsFile), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(app.calendarsFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write calendars file: %w", err)
+	}
+	return nil
+}
+
+// rescheduleCalendarJobs reschedules the jobs using the calendar, after it changed
+func (app *App) rescheduleCalendarJobs(calendarID string) {
+	app.mu.RLock()
+	var jobs []Job
+	for _, job := range app.jobs {
+		if job.Calendar == calendarID {
+			jobs = append(jobs, job)
+		}
+	}
+	app.mu.RUnlock()
+
+	for _, job := range jobs {
+		if err := app.scheduleJob(job); err != nil {
+			log.DefaultLogger.Error("Failed to reschedule job", "id", job.ID, "calendar", calendarID, "error", err)
+		}
+	}
+}
+
+// handleCalendars lists and creates calendars
+func (app *App) handleCalendars(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		app.calendarsMu.RLock()
+		calendars := make([]Calendar, 0, len(app.calendars))
+		for _, calendar := range app.calendars {
+			calendars = append(calendars, calendar)
+		}
+		app.calendarsMu.RUnlock()
+
+		sort.Slice(calendars, func(i, j int) bool {
+			return calendars[i].ID < calendars[j].ID
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(calendars)
+	case http.MethodPost:
+		var calendar Calendar
+		if err := json.NewDecoder(r.Body).Decode(&calendar); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		app.putCalendar(w, calendar, http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleCalendarByID handles /calendars/{id} and /calendars/{id}/import
+func (app *App) handleCalendarByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/calendars/")
+
+	if strings.HasSuffix(path, "/import") {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		app.importCalendar(w, r, strings.TrimSuffix(path, "/import"))
+		return
+	}
+
+	calendarID := path
+	app.calendarsMu.RLock()
+	calendar, exists := app.calendars[calendarID]
+	app.calendarsMu.RUnlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			http.Error(w, "Calendar not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(calendar)
+	case http.MethodPut:
+		if !exists {
+			http.Error(w, "Calendar not found", http.StatusNotFound)
+			return
+		}
+		var updated Calendar
+		if err := json.NewDecoder(r.Body).Decode(&updated); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		updated.ID = calendarID
+		app.putCalendar(w, updated, http.StatusOK)
+	case http.MethodDelete:
+		if !exists {
+			http.Error(w, "Calendar not found", http.StatusNotFound)
+			return
+		}
+		app.deleteCalendar(w, calendarID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// putCalendar validates and stores a calendar, then reschedules the jobs using it
+func (app *App) putCalendar(w http.ResponseWriter, calendar Calendar, status int) {
+	if calendar.ID == "" {
+		http.Error(w, "Calendar ID is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := calendar.compile(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid calendar: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	app.calendarsMu.Lock()
+	app.calendars[calendar.ID] = calendar
+	app.calendarsMu.Unlock()
+
+	if err := app.saveCalendars(); err != nil {
+		log.DefaultLogger.Error("Failed to save calendars", "error", err)
+	}
+	app.rescheduleCalendarJobs(calendar.ID)
+	log.DefaultLogger.Info("Saved calendar", "id", calendar.ID, "holidays", len(calendar.Holidays))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(calendar)
+}
+
+// deleteCalendar deletes a calendar that no job uses
+func (app *App) deleteCalendar(w http.ResponseWriter, calendarID string) {
+	app.mu.RLock()
+	var users []string
+	for _, job := range app.jobs {
+		if job.Calendar == calendarID {
+			users = append(users, job.ID)
+		}
+	}
+	app.mu.RUnlock()
+
+	if len(users) > 0 {
+		sort.Strings(users)
+		http.Error(w, fmt.Sprintf("Calendar is used by jobs: %s", strings.Join(users, ", ")), http.StatusConflict)
+		return
+	}
+
+	app.calendarsMu.Lock()
+	delete(app.calendars, calendarID)
+	app.calendarsMu.Unlock()
+
+	if err := app.saveCalendars(); err != nil {
+		log.DefaultLogger.Error("Failed to save calendars", "error", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// importCalendar adds the events of an iCalendar (.ics) file to a calendar's holidays, creating the
+// calendar if needed. With ?replace=true, the imported events replace the existing holidays.
+func (app *App) importCalendar(w http.ResponseWriter, r *http.Request, calendarID string) {
+	holidays, err := parseICS(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid iCalendar file: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	app.calendarsMu.RLock()
+	calendar, exists := app.calendars[calendarID]
+	app.calendarsMu.RUnlock()
+
+	status := http.StatusOK
+	if !exists {
+		calendar = Calendar{ID: calendarID, Name: calendarID}
+		status = http.StatusCreated
+	}
+	if r.URL.Query().Get("replace") == "true" {
+		calendar.Holidays = nil
+	}
+	calendar.Holidays = mergeHolidays(calendar.Holidays, holidays)
+
+	app.putCalendar(w, calendar, status)
+}
+
+// mergeHolidays adds the holidays not already listed, keeping them sorted by date
+func mergeHolidays(existing, added []Holiday) []Holiday {
+	seen := make(map[string]bool, len(existing))
+	for _, holiday := range existing {
+		seen[holiday.Date] = true
+	}
+	merged := append([]Holiday{}, existing...)
+	for _, holiday := range added {
+		if !seen[holiday.Date] {
+			seen[holiday.Date] = true
+			merged = append(merged, holiday)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Date < merged[j].Date
+	})
+	return merged
+}
+
+// parseICS returns the days covered by the events of an iCalendar file. Recurring events (RRULE)
+// are not expanded: only their first occurrence is imported.
+func parseICS(r io.Reader) ([]Holiday, error) {
+	// Unfold continuation lines, which start with a space or a tab
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || !strings.EqualFold(strings.TrimSpace(lines[0]), "BEGIN:VCALENDAR") {
+		return nil, fmt.Errorf("missing BEGIN:VCALENDAR")
+	}
+
+	var holidays []Holiday
+	var inEvent bool
+	var summary string
+	var start, end time.Time
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		property, params, _ := strings.Cut(name, ";")
+		property = strings.ToUpper(property)
+
+		switch {
+		case property == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			inEvent, summary, start, end = true, "", time.Time{}, time.Time{}
+		case property == "END" && strings.EqualFold(value, "VEVENT"):
+			if start.IsZero() {
+				return nil, fmt.Errorf("event %q without DTSTART", summary)
+			}
+			// All-day events end on the day after their last day (DTEND is exclusive)
+			if end.IsZero() || !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+				holidays = append(holidays, Holiday{Date: day.Format(dateLayout), Name: summary})
+			}
+			inEvent = false
+		case !inEvent:
+		case property == "SUMMARY":
+			summary = unescapeICS(value)
+		case property == "DTSTART", property == "DTEND":
+			day, err := parseICSDate(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", property, value, err)
+			}
+			if property == "DTSTART" {
+				start = day
+			} else {
+				end = day
+			}
+		}
+	}
+
+	return holidays, nil
+}
+
+// parseICSDate returns the day of an iCalendar DATE or DATE-TIME value
+func parseICSDate(value, params string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("too short")
+	}
+
+	loc := time.UTC
+	for _, param := range strings.Split(params, ";") {
+		if key, tzid, ok := strings.Cut(param, "="); ok && strings.EqualFold(key, "TZID") {
+			if l, err := time.LoadLocation(strings.Trim(tzid, `"`)); err == nil {
+				loc = l
+			}
+		}
+	}
+
+	if len(value) == 8 {
+		return time.ParseInLocation("20060102", value, time.UTC)
+	}
+
+	layout := "20060102T150405"
+	if strings.HasSuffix(value, "Z") {
+		layout += "Z"
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// unescapeICS decodes iCalendar text escapes
+func unescapeICS(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
@@ -0,0 +1,172 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/robfig/cron/v3"
+)
+
+func mustCompile(t *testing.T, c Calendar) *businessCalendar {
+	t.Helper()
+	bc, err := c.compile()
+	if err != nil {
+		t.Fatalf("Failed to compile calendar: %v", err)
+	}
+	return bc
+}
+
+func TestCalendarScheduleSkip(t *testing.T) {
+	// Monday 2024-12-23 to Friday 2024-12-27, with Christmas on Wednesday
+	bc := mustCompile(t, Calendar{Holidays: []Holiday{{Date: "2024-12-25"}}})
+	daily, _ := cron.ParseStandard("0 9 * * *")
+	schedule := calendarSchedule{schedule: daily, calendar: bc}
+
+	tests := []struct {
+		from time.Time
+		want time.Time
+	}{
+		{time.Date(2024, 12, 24, 10, 0, 0, 0, time.UTC), time.Date(2024, 12, 26, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 12, 27, 10, 0, 0, 0, time.UTC), time.Date(2024, 12, 30, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+		}
+	}
+
+	// Frequent jobs skip whole excluded days
+	everyMinute, _ := cron.ParseStandard("* * * * *")
+	schedule = calendarSchedule{schedule: everyMinute, calendar: bc}
+	if got, want := schedule.Next(time.Date(2024, 12, 27, 23, 59, 30, 0, time.UTC)), time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected every-minute job to resume on Monday, got %v", got)
+	}
+}
+
+func TestCalendarScheduleNextBusinessDay(t *testing.T) {
+	bc := mustCompile(t, Calendar{Workdays: []string{"mon", "tue", "wed", "thu", "fri"}})
+
+	// Daily occurrences on the weekend are merged with Monday's
+	daily, _ := cron.ParseStandard("0 9 * * *")
+	schedule := calendarSchedule{schedule: daily, calendar: bc, policy: calendarNextBusinessDay}
+	friday := time.Date(2024, 12, 27, 10, 0, 0, 0, time.UTC)
+	monday := time.Date(2024, 12, 30, 9, 0, 0, 0, time.UTC)
+	if got := schedule.Next(friday); !got.Equal(monday) {
+		t.Errorf("Expected Monday, got %v", got)
+	}
+	if got, want := schedule.Next(monday), monday.AddDate(0, 0, 1); !got.Equal(want) {
+		t.Errorf("Expected Tuesday, got %v", got)
+	}
+
+	// A weekly Saturday report moves to Monday, even when asked for the next run on Sunday
+	saturdays, _ := cron.ParseStandard("0 9 * * 6")
+	schedule = calendarSchedule{schedule: saturdays, calendar: bc, policy: calendarNextBusinessDay}
+	sunday := time.Date(2024, 12, 29, 12, 0, 0, 0, time.UTC)
+	if got := schedule.Next(sunday); !got.Equal(monday) {
+		t.Errorf("Expected Saturday's run on Monday, got %v", got)
+	}
+}
+
+func TestCalendarCompileValidation(t *testing.T) {
+	if _, err := (Calendar{Workdays: []string{"funday"}}).compile(); err == nil {
+		t.Error("Expected error for invalid workday")
+	}
+	if _, err := (Calendar{Holidays: []Holiday{{Date: "25/12/2024"}}}).compile(); err == nil {
+		t.Error("Expected error for invalid holiday date")
+	}
+}
+
+func TestParseICS(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20241225",
+		"DTEND;VALUE=DATE:20241227",
+		"SUMMARY:Christmas and",
+		"  Boxing Day",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;TZID=Pacific/Auckland:20250101T080000",
+		"SUMMARY:New Year\\, observed",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20250714T230000Z",
+		"DTEND:20250715T010000Z",
+		"SUMMARY:Bastille Day",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	holidays, err := parseICS(strings.NewReader(ics))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	want := []Holiday{
+		{Date: "2024-12-25", Name: "Christmas and Boxing Day"},
+		{Date: "2024-12-26", Name: "Christmas and Boxing Day"},
+		{Date: "2025-01-01", Name: "New Year, observed"},
+		{Date: "2025-07-14", Name: "Bastille Day"},
+	}
+	if len(holidays) != len(want) {
+		t.Fatalf("Expected %d holidays, got %+v", len(want), holidays)
+	}
+	for i := range want {
+		if holidays[i] != want[i] {
+			t.Errorf("Holiday %d = %+v, want %+v", i, holidays[i], want[i])
+		}
+	}
+
+	if _, err := parseICS(strings.NewReader("not a calendar")); err == nil {
+		t.Error("Expected error for invalid file")
+	}
+}
+
+func TestCalendarAPI(t *testing.T) {
+	app := newTestApp(t)
+	admin := &backend.User{Login: "admin", Role: roleAdmin}
+
+	resp := callResource(t, app, admin, http.MethodPost, "/jobs", `{"id": "ops", "cron": "0 9 * * *", "calendar": "fr"}`)
+	if resp.Status != http.StatusBadRequest {
+		t.Errorf("Expected job with unknown calendar to be rejected, got %d", resp.Status)
+	}
+
+	resp = callResource(t, app, admin, http.MethodPost, "/calendars/fr/import", "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20250714\nSUMMARY:Bastille Day\nEND:VEVENT\nEND:VCALENDAR\n")
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("Expected import to create the calendar, got %d: %s", resp.Status, resp.Body)
+	}
+
+	var calendar Calendar
+	json.Unmarshal(resp.Body, &calendar)
+	if len(calendar.Holidays) != 1 || calendar.Holidays[0].Date != "2025-07-14" {
+		t.Errorf("Unexpected imported calendar: %+v", calendar)
+	}
+
+	resp = callResource(t, app, admin, http.MethodPost, "/jobs", `{"id": "ops", "cron": "0 9 * * *", "calendar": "fr", "calendarPolicy": "nextBusinessDay"}`)
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("Expected job to be created, got %d: %s", resp.Status, resp.Body)
+	}
+	if _, scheduled := app.cronIDs["ops"]; !scheduled {
+		t.Error("Expected job with calendar to be scheduled")
+	}
+
+	resp = callResource(t, app, admin, http.MethodDelete, "/calendars/fr", "")
+	if resp.Status != http.StatusConflict {
+		t.Errorf("Expected deleting a calendar in use to fail, got %d", resp.Status)
+	}
+
+	resp = callResource(t, app, admin, http.MethodPut, "/calendars/fr", `{"workdays": ["mon", "someday"]}`)
+	if resp.Status != http.StatusBadRequest {
+		t.Errorf("Expected invalid calendar to be rejected, got %d", resp.Status)
+	}
+
+	// Calendars survive a restart
+	if err := app.loadCalendars(); err != nil || len(app.calendars) != 1 {
+		t.Errorf("Expected the calendar to be saved, got %v, %v", app.calendars, err)
+	}
+}
@@ -0,0 +1,214 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Layout of composed comparison images, in pixels
+const (
+	comparisonMargin      = 20
+	comparisonLabelHeight = 30
+)
+
+// renderedPeriod is the rendered report of one of the periods of a comparison
+type renderedPeriod struct {
+	label string
+	data  []byte
+}
+
+// parseCompareOffset parses a comparison offset such as "1w", "7d" or "1y": an optional count and a unit of date math
+func parseCompareOffset(offset string) (int, string, error) {
+	digits := len(offset) - len(strings.TrimLeft(offset, "0123456789"))
+	count := 1
+	if digits > 0 {
+		count, _ = strconv.Atoi(offset[:digits])
+	}
+	unit := offset[digits:]
+	if count < 1 || !isTimeUnit(unit) || strings.HasPrefix(unit, "f") {
+		return 0, "", fmt.Errorf("invalid compare offset %q (expected a count and a unit such as 1w, 1M or 1y)", offset)
+	}
+	return count, unit, nil
+}
+
+// validateCompareOffset checks the job's comparison offset, which requires a time range that can be shifted
+func (job Job) validateCompareOffset() error {
+	if job.CompareOffset == "" {
+		return nil
+	}
+	if _, _, err := parseCompareOffset(job.CompareOffset); err != nil {
+		return err
+	}
+	if job.TimeRange != "" {
+		return nil
+	}
+
+	resolver := timeRangeResolver{now: time.Now(), fiscalStart: 1}
+	for _, expr := range []string{job.From, job.To} {
+		if _, relative, _ := resolver.resolve(expr, false); relative {
+			continue
+		}
+		if _, err := strconv.ParseInt(expr, 10, 64); err != nil {
+			return fmt.Errorf("compareOffset requires relative or epoch milliseconds from/to, got %q", expr)
+		}
+	}
+	return nil
+}
+
+// comparisonJob returns the resolved job with its time range moved back by the compare offset.
+// The end of the range is shifted as an exclusive bound, so that the previous month of a full
+// month is also a full month.
+func comparisonJob(job Job) (Job, error) {
+	count, unit, err := parseCompareOffset(job.CompareOffset)
+	if err != nil {
+		return job, err
+	}
+	loc, err := job.location()
+	if err != nil {
+		return job, err
+	}
+
+	from, to, err := job.epochRange(loc)
+	if err != nil {
+		return job, err
+	}
+	from = addTimeUnit(from, unit, -count)
+	to = addTimeUnit(to.Add(time.Millisecond), unit, -count).Add(-time.Millisecond)
+
+	job.From = strconv.FormatInt(from.UnixMilli(), 10)
+	job.To = strconv.FormatInt(to.UnixMilli(), 10)
+	return job, nil
+}
+
+// epochRange returns the resolved from/to of the job, which must be epoch milliseconds, in loc
+func (job Job) epochRange(loc *time.Location) (time.Time, time.Time, error) {
+	from, err := strconv.ParseInt(job.From, 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("cannot compare periods of non-epoch time %q", job.From)
+	}
+	to, err := strconv.ParseInt(job.To, 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("cannot compare periods of non-epoch time %q", job.To)
+	}
+	return time.UnixMilli(from).In(loc), time.UnixMilli(to).In(loc), nil
+}
+
+// periodLabel describes the resolved time range of the job
+func periodLabel(prefix string, job Job) string {
+	loc, err := job.location()
+	if err != nil {
+		loc = time.UTC
+	}
+	from, to, err := job.epochRange(loc)
+	if err != nil {
+		return prefix
+	}
+	const layout = "2006-01-02 15:04"
+	return fmt.Sprintf("%s: %s to %s (%s)", prefix, from.Format(layout), to.Format(layout), loc)
+}
+
+// renderComparison renders the resolved job for its own period and for the period moved back by
+// its compare offset. For PNG reports, the two renders are also composed side by side into a
+// single image; HTML emails and PDF reports show them one after the other.
+func (app *App) renderComparison(ctx context.Context, job Job) ([]byte, []renderedPeriod, error) {
+	// Periods composed side by side are only cropped; the composed image is then scaled down and
+	// watermarked once, so that it fits the maximum width and carries a single watermark
+	sideBySide := job.Format != "html" && job.Format != "pdf"
+	currentJob := job
+	if sideBySide {
+		currentJob = job.withImageOptions(func(options *ImageOptions) {
+			options.MaxWidth = 0
+			options.Watermark = nil
+		})
+	}
+	previousJob, err := comparisonJob(currentJob)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current, err := app.renderImage(ctx, currentJob)
+	if err != nil {
+		return nil, nil, err
+	}
+	previous, err := app.renderImage(ctx, previousJob)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render comparison period: %w", err)
+	}
+
+	periods := []renderedPeriod{
+		{label: periodLabel("Current period", job), data: current},
+		{label: periodLabel(fmt.Sprintf("Previous period (%s earlier)", job.CompareOffset), previousJob), data: previous},
+	}
+	if !sideBySide {
+		return current, periods, nil
+	}
+
+	composed, err := composeSideBySide(periods)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compose comparison: %w", err)
+	}
+	composedJob := job.withImageOptions(func(options *ImageOptions) {
+		options.Crop = nil
+	})
+	if composed, err = app.processImage(composedJob, composed, time.Now()); err != nil {
+		return nil, nil, fmt.Errorf("failed to process image: %w", err)
+	}
+	return composed, periods, nil
+}
+
+// composeSideBySide draws the PNG images of the periods next to each other on a white
+// background, each with its label above it, and encodes the result as PNG
+func composeSideBySide(periods []renderedPeriod) ([]byte, error) {
+	images := make([]image.Image, len(periods))
+	width, height := comparisonMargin, 0
+	for i, period := range periods {
+		img, err := png.Decode(bytes.NewReader(period.data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode rendered image: %w", err)
+		}
+		images[i] = img
+		width += img.Bounds().Dx() + comparisonMargin
+		if h := img.Bounds().Dy(); h > height {
+			height = h
+		}
+	}
+	height += comparisonLabelHeight + 2*comparisonMargin
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+
+	face := basicfont.Face7x13
+	x := comparisonMargin
+	for i, img := range images {
+		drawer := font.Drawer{
+			Dst:  canvas,
+			Src:  image.NewUniform(color.Black),
+			Face: face,
+			Dot:  fixed.P(x, comparisonMargin+face.Ascent),
+		}
+		drawer.DrawString(periods[i].label)
+
+		top := comparisonMargin + comparisonLabelHeight
+		bounds := img.Bounds()
+		draw.Draw(canvas, image.Rect(x, top, x+bounds.Dx(), top+bounds.Dy()), img, bounds.Min, draw.Over)
+		x += bounds.Dx() + comparisonMargin
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,180 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testPNG(t *testing.T, width, height int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestValidateCompareOffset(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     Job
+		wantErr bool
+	}{
+		{name: "no comparison", job: Job{From: "2025-01-01", To: "2025-02-01"}},
+		{name: "week over week", job: Job{From: "now-7d", To: "now", CompareOffset: "1w"}},
+		{name: "year over year preset", job: Job{TimeRange: "previousMonth", CompareOffset: "1y"}},
+		{name: "epoch range", job: Job{From: "1700000000000", To: "1700003600000", CompareOffset: "24h"}},
+		{name: "unit without count", job: Job{From: "now/M", To: "now", CompareOffset: "M"}},
+		{name: "unknown unit", job: Job{From: "now-7d", To: "now", CompareOffset: "1 week"}, wantErr: true},
+		{name: "fiscal unit", job: Job{From: "now-7d", To: "now", CompareOffset: "1fy"}, wantErr: true},
+		{name: "zero count", job: Job{From: "now-7d", To: "now", CompareOffset: "0d"}, wantErr: true},
+		{name: "date range", job: Job{From: "2025-01-01", To: "2025-02-01", CompareOffset: "1M"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.job.validateCompareOffset(); (err != nil) != tt.wantErr {
+				t.Errorf("validateCompareOffset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestComparisonJob(t *testing.T) {
+	app := newTestApp(t)
+	job, err := app.resolveTimeRange(Job{TimeRange: "previousMonth", Timezone: "UTC", CompareOffset: "1M"}, time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous, err := comparisonJob(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The month before February is the whole of January
+	wantFrom := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	wantTo := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC).UnixMilli() - 1
+	if previous.From != strconv.FormatInt(wantFrom, 10) || previous.To != strconv.FormatInt(wantTo, 10) {
+		t.Errorf("Expected %d-%d, got %s-%s", wantFrom, wantTo, previous.From, previous.To)
+	}
+
+	if got, want := periodLabel("Previous period", previous), "Previous period: 2025-01-01 00:00 to 2025-01-31 23:59 (UTC)"; got != want {
+		t.Errorf("periodLabel() = %q, want %q", got, want)
+	}
+}
+
+func TestComposeSideBySide(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	composed, err := composeSideBySide([]renderedPeriod{
+		{label: "Current", data: testPNG(t, 40, 20, red)},
+		{label: "Previous", data: testPNG(t, 30, 50, blue)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(composed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantWidth := comparisonMargin + 40 + comparisonMargin + 30 + comparisonMargin
+	wantHeight := 50 + comparisonLabelHeight + 2*comparisonMargin
+	if img.Bounds().Dx() != wantWidth || img.Bounds().Dy() != wantHeight {
+		t.Fatalf("Expected %dx%d image, got %v", wantWidth, wantHeight, img.Bounds())
+	}
+
+	top := comparisonMargin + comparisonLabelHeight
+	if got := color.RGBAModel.Convert(img.At(comparisonMargin+5, top+5)); got != red {
+		t.Errorf("Expected the current period on the left, got %v", got)
+	}
+	if got := color.RGBAModel.Convert(img.At(2*comparisonMargin+40+5, top+45)); got != blue {
+		t.Errorf("Expected the previous period on the right, got %v", got)
+	}
+
+	if _, err := composeSideBySide([]renderedPeriod{{data: []byte("not a png")}}); err == nil {
+		t.Error("Expected error for invalid image")
+	}
+}
+
+func TestExecuteJobRendersComparison(t *testing.T) {
+	var mu sync.Mutex
+	var froms []int64
+	frame := testPNG(t, 10, 10, color.White)
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, _ := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+		mu.Lock()
+		froms = append(froms, from)
+		mu.Unlock()
+		w.Write(frame)
+	}))
+	defer grafana.Close()
+
+	app := newTestApp(t)
+	app.config.GrafanaURL = grafana.URL
+
+	job, err := app.resolveTimeRange(Job{ID: "wow", DashboardUID: "abc", Format: "png", From: "now-1d/d", To: "now-1d/d", Timezone: "UTC", CompareOffset: "1w"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SMTP is not configured, so only rendering succeeds
+	if err := app.executeJob(context.Background(), job); err == nil {
+		t.Fatal("Expected email error")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(froms) != 2 {
+		t.Fatalf("Expected both periods to be rendered, got %d requests", len(froms))
+	}
+	if diff := time.Duration(froms[0]-froms[1]) * time.Millisecond; diff != 7*24*time.Hour {
+		t.Errorf("Expected the comparison period one week earlier, got %v", diff)
+	}
+}
+
+func TestRenderComparisonScalesComposedImage(t *testing.T) {
+	frame := testPNG(t, 80, 40, color.White)
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(frame)
+	}))
+	defer grafana.Close()
+
+	app := newTestApp(t)
+	app.config.GrafanaURL = grafana.URL
+
+	job, err := app.resolveTimeRange(Job{ID: "wow", DashboardUID: "abc", Format: "png", From: "now-1d/d", To: "now-1d/d", CompareOffset: "1w",
+		Image: &ImageOptions{MaxWidth: 100, Crop: &CropRegion{Width: 60, Height: 40}}}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	composed, periods, err := app.renderComparison(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(composed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 100 {
+		t.Errorf("Expected the composed image to be scaled down to 100px, got %v", img.Bounds())
+	}
+	for _, period := range periods {
+		config, err := png.DecodeConfig(bytes.NewReader(period.data))
+		if err != nil || config.Width != 60 {
+			t.Errorf("Expected each period to be cropped to 60px before composing, got %d (%v)", config.Width, err)
+		}
+	}
+}
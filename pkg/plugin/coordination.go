@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// claimRetention is how long claim files are kept before being cleaned up
+const claimRetention = 7 * 24 * time.Hour
+
+// claimFormat names claim files after the occurrence, to the second like the finest schedules
+const claimFormat = "20060102T150405Z"
+
+// Claim outcomes used as the "outcome" label of metricClaims
+const (
+	claimWon   = "won"
+	claimLost  = "lost"
+	claimError = "error"
+)
+
+// coordinator makes replicas sharing a directory agree on which of them fires each job occurrence.
+//
+// Every replica schedules every job. When an occurrence fires, each replica tries to create the
+// occurrence's claim file with O_EXCL, which succeeds for exactly one of them; the others skip the
+// occurrence. There is no long-lived leader, so when a replica disappears the remaining ones keep
+// claiming occurrences without any failover delay.
+type coordinator struct {
+	dir     string
+	replica string
+}
+
+// newCoordinator returns a coordinator using dir, which must be shared by all replicas
+func newCoordinator(dir, replica string) *coordinator {
+	if replica == "" {
+		hostname, _ := os.Hostname()
+		replica = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	return &coordinator{dir: dir, replica: replica}
+}
+
+// claim reports whether this replica won the job occurrence. A nil coordinator wins every claim.
+func (c *coordinator) claim(jobID string, occurrence time.Time) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+
+	jobDir := filepath.Join(c.dir, "claims", url.PathEscape(jobID))
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create claims directory: %w", err)
+	}
+
+	path := filepath.Join(jobDir, occurrence.UTC().Format(claimFormat)+".claim")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create claim file: %w", err)
+	}
+	_, err = fmt.Fprintf(f, "%s\n", c.replica)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to write claim file: %w", err)
+	}
+
+	c.cleanup(jobDir, time.Now())
+	return true, nil
+}
+
+// cleanup removes the job's claims older than claimRetention
+func (c *coordinator) cleanup(jobDir string, now time.Time) {
+	entries, err := os.ReadDir(jobDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < claimRetention {
+			continue
+		}
+		if err := os.Remove(filepath.Join(jobDir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.DefaultLogger.Warn("Failed to remove old claim", "file", entry.Name(), "error", err)
+		}
+	}
+}
+
+// check verifies that the shared directory is writable
+func (c *coordinator) check() error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create coordination directory: %w", err)
+	}
+	probe := filepath.Join(c.dir, fmt.Sprintf(".probe-%s", url.PathEscape(c.replica)))
+	if err := os.WriteFile(probe, []byte(c.replica), 0644); err != nil {
+		return fmt.Errorf("coordination directory is not writable: %w", err)
+	}
+	return os.Remove(probe)
+}
+
+// claimOccurrence reports whether this replica should fire the job occurrence. When the shared
+// directory cannot be used, the occurrence is fired anyway: a duplicate report is better than none.
+func (app *App) claimOccurrence(jobID string, occurrence time.Time) bool {
+	won, err := app.coordinator.claim(jobID, occurrence)
+	switch {
+	case err != nil:
+		metricClaims.WithLabelValues(claimError).Inc()
+		log.DefaultLogger.Error("Failed to claim job occurrence, firing it anyway", "id", jobID, "occurrence", occurrence, "error", err)
+		return true
+	case !won:
+		metricClaims.WithLabelValues(claimLost).Inc()
+		log.DefaultLogger.Info("Job occurrence claimed by another replica", "id", jobID, "occurrence", occurrence)
+		return false
+	default:
+		metricClaims.WithLabelValues(claimWon).Inc()
+		return true
+	}
+}
+
+// checkCoordination verifies the shared coordination directory, when replicas are coordinated
+func (app *App) checkCoordination(ctx context.Context, config Config) error {
+	if app.coordinator == nil {
+		return nil
+	}
+	return app.coordinator.check()
+}
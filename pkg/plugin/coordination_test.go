@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestCoordinatorClaim(t *testing.T) {
+	dir := t.TempDir()
+	first := newCoordinator(dir, "replica-1")
+	second := newCoordinator(dir, "replica-2")
+	occurrence := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	if won, err := first.claim("daily", occurrence); err != nil || !won {
+		t.Fatalf("Expected first replica to win, got %v, %v", won, err)
+	}
+	if won, err := second.claim("daily", occurrence); err != nil || won {
+		t.Fatalf("Expected second replica to lose, got %v, %v", won, err)
+	}
+	if won, _ := second.claim("daily", occurrence.Add(24*time.Hour)); !won {
+		t.Error("Expected second replica to win the next occurrence")
+	}
+	if won, _ := second.claim("daily", occurrence.Add(10*time.Second)); !won {
+		t.Error("Expected occurrences within the same minute to be claimed separately")
+	}
+	if won, _ := second.claim("weekly/report", occurrence); !won {
+		t.Error("Expected claims to be per job")
+	}
+
+	var none *coordinator
+	if won, err := none.claim("daily", occurrence); err != nil || !won {
+		t.Errorf("Expected a nil coordinator to win every claim, got %v, %v", won, err)
+	}
+}
+
+func TestCoordinatorCleanup(t *testing.T) {
+	dir := t.TempDir()
+	c := newCoordinator(dir, "replica-1")
+	old := time.Now().Add(-30 * 24 * time.Hour)
+
+	c.claim("daily", old)
+	jobDir := filepath.Join(dir, "claims", "daily")
+	oldClaim := filepath.Join(jobDir, old.UTC().Format(claimFormat)+".claim")
+	os.Chtimes(oldClaim, old, old)
+
+	c.claim("daily", time.Now())
+
+	if _, err := os.Stat(oldClaim); !os.IsNotExist(err) {
+		t.Error("Expected old claim to be removed")
+	}
+	entries, _ := os.ReadDir(jobDir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the recent claim to remain, got %d entries", len(entries))
+	}
+}
+
+func TestReplicasFireOccurrenceOnce(t *testing.T) {
+	var renders int32
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&renders, 1)
+		w.Write([]byte("png"))
+	}))
+	defer grafana.Close()
+
+	shared := t.TempDir()
+	job := Job{ID: "daily", Cron: "0 9 * * *", Format: "png"}
+	occurrence := time.Now().Truncate(time.Minute)
+
+	var wg sync.WaitGroup
+	var fired int32
+	for _, replica := range []string{"replica-1", "replica-2", "replica-3"} {
+		app := newTestApp(t)
+		app.config.GrafanaURL = grafana.URL
+		app.coordinator = newCoordinator(shared, replica)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := app.runOccurrence(job, occurrence, triggerSchedule); ok {
+				atomic.AddInt32(&fired, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if fired != 1 || renders != 1 {
+		t.Errorf("Expected the occurrence to fire once, fired %d times with %d renders", fired, renders)
+	}
+}
+
+func TestScheduledOccurrence(t *testing.T) {
+	app := newTestApp(t)
+	job := Job{ID: "frequent", Cron: "@every 1s", Format: "png"}
+
+	// Sub-minute schedules get a distinct occurrence, planned to the second, for each run
+	occurrences := make(chan time.Time, 2)
+	schedule, _ := app.jobSchedule(job)
+	app.mu.Lock()
+	app.cronIDs[job.ID] = app.scheduler.Schedule(schedule, cron.FuncJob(func() {
+		select {
+		case occurrences <- app.scheduledOccurrence(job):
+		default:
+		}
+	}))
+	app.mu.Unlock()
+	app.scheduler.Start()
+
+	var got []time.Time
+	for len(got) < 2 {
+		select {
+		case occurrence := <-occurrences:
+			got = append(got, occurrence)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the job to fire twice")
+		}
+	}
+	if !got[0].Equal(got[0].Truncate(time.Second)) || got[1].Sub(got[0]) != time.Second {
+		t.Errorf("Expected planned occurrences one second apart, got %v", got)
+	}
+
+	runAt := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	if occurrence := app.scheduledOccurrence(Job{ID: "once", RunAt: &runAt}); !occurrence.Equal(runAt) {
+		t.Errorf("Expected one-shot jobs to use runAt, got %v", occurrence)
+	}
+}
@@ -0,0 +1,313 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Limits of the number of fire times returned by /cron/preview
+const (
+	defaultPreviewCount = 5
+	maxPreviewCount     = 100
+)
+
+// CronPreview is the response of /cron/preview
+type CronPreview struct {
+	Expression  string      `json:"expression"`
+	Timezone    string      `json:"timezone"`
+	Description string      `json:"description"`
+	NextRuns    []time.Time `json:"nextRuns"`
+}
+
+// jobWithSchedule is a job as returned by listJobs, with its lifecycle state and fire times from the scheduler
+type jobWithSchedule struct {
+	Job
+	State   string     `json:"state"`
+	NextRun *time.Time `json:"nextRun,omitempty"`
+	PrevRun *time.Time `json:"prevRun,omitempty"` // Last fire since the plugin started
+}
+
+// handleCronPreview parses a cron expression and returns its next fire times and a description
+//
+// Query parameters: expr (required), tz (IANA time zone, default: the scheduler's local time zone)
+// and count (number of fire times, default 5, at most 100).
+func (app *App) handleCronPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	expr := strings.TrimSpace(query.Get("expr"))
+	if expr == "" {
+		http.Error(w, "Missing expr parameter", http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid cron expression: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	loc := time.Local
+	if tz := query.Get("tz"); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid time zone: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	count := defaultPreviewCount
+	if value := query.Get("count"); value != "" {
+		if count, err = strconv.Atoi(value); err != nil || count < 1 || count > maxPreviewCount {
+			http.Error(w, fmt.Sprintf("count must be between 1 and %d", maxPreviewCount), http.StatusBadRequest)
+			return
+		}
+	}
+
+	preview := CronPreview{
+		Expression:  expr,
+		Timezone:    loc.String(),
+		Description: describeCron(expr),
+		NextRuns:    make([]time.Time, 0, count),
+	}
+	for next := schedule.Next(time.Now().In(loc)); !next.IsZero() && len(preview.NextRuns) < count; next = schedule.Next(next) {
+		preview.NextRuns = append(preview.NextRuns, next)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// withSchedule adds the job's lifecycle state and its next and previous fire times from the scheduler
+func (app *App) withSchedule(job Job) jobWithSchedule {
+	result := jobWithSchedule{Job: job.redacted(), State: job.lifecycleState(time.Now())}
+
+	app.mu.RLock()
+	entryID, ok := app.cronIDs[job.ID]
+	app.mu.RUnlock()
+	if !ok {
+		return result
+	}
+
+	entry := app.scheduler.Entry(entryID)
+	if !entry.Next.IsZero() {
+		next := entry.Next
+		result.NextRun = &next
+	}
+	if !entry.Prev.IsZero() {
+		prev := entry.Prev
+		result.PrevRun = &prev
+	}
+	return result
+}
+
+// cronField describes how the values of one field of a cron expression are named
+type cronField struct {
+	unit  string
+	names []string // names accepted for the values, indexed from the field's first value
+	first int
+	label func(value int) string
+}
+
+var (
+	minuteField = cronField{unit: "minute", label: strconv.Itoa}
+	hourField   = cronField{unit: "hour", label: strconv.Itoa}
+	domField    = cronField{unit: "day", label: strconv.Itoa}
+	monthField  = cronField{
+		unit:  "month",
+		names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"},
+		first: 1,
+		label: func(value int) string { return time.Month(value).String() },
+	}
+	dowField = cronField{
+		unit:  "weekday",
+		names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"},
+		label: func(value int) string { return time.Weekday(value % 7).String() },
+	}
+)
+
+// cronDescriptors describes the predefined schedules accepted by cron.ParseStandard
+var cronDescriptors = map[string]string{
+	"@yearly":   "At 00:00 on January 1",
+	"@annually": "At 00:00 on January 1",
+	"@monthly":  "At 00:00 on day 1 of the month",
+	"@weekly":   "At 00:00 on Sunday",
+	"@daily":    "At 00:00 every day",
+	"@midnight": "At 00:00 every day",
+	"@hourly":   "At minute 0 of every hour",
+}
+
+// describeCron returns a human-readable description of a valid cron expression
+func describeCron(expr string) string {
+	expr = strings.TrimSpace(expr)
+
+	var zone string
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		i := strings.Index(expr, " ")
+		if i < 0 {
+			return expr
+		}
+		zone = expr[strings.Index(expr, "=")+1 : i]
+		expr = strings.TrimSpace(expr[i:])
+	}
+
+	description := describeSpec(expr)
+	if zone != "" {
+		description += " (" + zone + ")"
+	}
+	return description
+}
+
+func describeSpec(expr string) string {
+	if description, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		return description
+	}
+	if strings.HasPrefix(expr, "@every ") {
+		return "Every " + strings.TrimSpace(strings.TrimPrefix(expr, "@every "))
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return expr
+	}
+	for i, field := range fields {
+		if field == "?" {
+			fields[i] = "*"
+		}
+	}
+	minute, hour, dom, month, dow := fields[0], fields[1], fields[2], fields[3], fields[4]
+
+	var parts []string
+	atTimes := false
+
+	switch {
+	case isPlainList(minute) && isPlainList(hour) && !strings.Contains(minute, ","):
+		m, _ := strconv.Atoi(minute)
+		var times []string
+		for _, h := range strings.Split(hour, ",") {
+			hv, _ := strconv.Atoi(h)
+			times = append(times, fmt.Sprintf("%02d:%02d", hv, m))
+		}
+		parts = append(parts, "At "+joinWords(times))
+		atTimes = true
+	case minute == "*":
+		parts = append(parts, "Every minute")
+	case strings.HasPrefix(minute, "*/"):
+		parts = append(parts, "Every "+describeCronField(minute, minuteField))
+	default:
+		parts = append(parts, "At "+pluralize("minute", minute)+" "+describeCronField(minute, minuteField))
+	}
+
+	if !atTimes {
+		switch {
+		case hour == "*" && minute != "*" && !strings.HasPrefix(minute, "*/"):
+			parts[0] += " of every hour"
+		case hour == "*":
+		case strings.HasPrefix(hour, "*/"):
+			parts = append(parts, "every "+describeCronField(hour, hourField))
+		default:
+			parts = append(parts, "during "+pluralize("hour", hour)+" "+describeCronField(hour, hourField))
+		}
+	}
+
+	switch {
+	case dom == "*" && dow == "*":
+		if atTimes {
+			parts = append(parts, "every day")
+		}
+	case dow == "*":
+		parts = append(parts, "on "+pluralize("day", dom)+" "+describeCronField(dom, domField)+" of the month")
+	case dom == "*":
+		parts = append(parts, "on "+describeCronField(dow, dowField))
+	default:
+		// Like standard cron, a job with both fields restricted runs when either matches
+		parts = append(parts, "on "+pluralize("day", dom)+" "+describeCronField(dom, domField)+" of the month or on "+describeCronField(dow, dowField))
+	}
+
+	if month != "*" {
+		parts = append(parts, "in "+describeCronField(month, monthField))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// describeCronField describes a field made of comma-separated values, ranges and steps
+func describeCronField(field string, f cronField) string {
+	var items []string
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step, hasStep := strings.Cut(item, "/")
+
+		var from, to string
+		if rangePart != "*" {
+			start, end, isRange := strings.Cut(rangePart, "-")
+			from = f.value(start)
+			if isRange {
+				to = f.value(end)
+			}
+		}
+
+		switch {
+		case hasStep && rangePart == "*":
+			items = append(items, fmt.Sprintf("%s %ss", step, f.unit))
+		case hasStep && to != "":
+			items = append(items, fmt.Sprintf("every %s %ss from %s through %s", step, f.unit, from, to))
+		case hasStep:
+			items = append(items, fmt.Sprintf("every %s %ss starting at %s", step, f.unit, from))
+		case rangePart == "*":
+			items = append(items, "every "+f.unit)
+		case to != "":
+			items = append(items, from+" through "+to)
+		default:
+			items = append(items, from)
+		}
+	}
+	return joinWords(items)
+}
+
+// value returns the label of a field value given as a number or a name
+func (f cronField) value(s string) string {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.label(f.first + i)
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return s
+	}
+	return f.label(n)
+}
+
+// isPlainList reports whether a field is a list of numbers, without ranges or steps
+func isPlainList(field string) bool {
+	for _, item := range strings.Split(field, ",") {
+		if _, err := strconv.Atoi(item); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// pluralize returns the unit, in the plural when the field matches several values
+func pluralize(unit, field string) string {
+	if strings.ContainsAny(field, ",-/") {
+		return unit + "s"
+	}
+	return unit
+}
+
+// joinWords joins items as "a", "a and b" or "a, b and c"
+func joinWords(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
@@ -0,0 +1,118 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestDescribeCron(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"0 9 * * *", "At 09:00, every day"},
+		{"30 8,17 * * *", "At 08:30 and 17:30, every day"},
+		{"0 9 * * 1-5", "At 09:00, on Monday through Friday"},
+		{"0 9 * * MON,WED", "At 09:00, on Monday and Wednesday"},
+		{"0 8 1 * *", "At 08:00, on day 1 of the month"},
+		{"0 8 1,15 * *", "At 08:00, on days 1 and 15 of the month"},
+		{"0 8 1 jan-mar *", "At 08:00, on day 1 of the month, in January through March"},
+		{"0 8 1 * 1", "At 08:00, on day 1 of the month or on Monday"},
+		{"*/15 * * * *", "Every 15 minutes"},
+		{"*/15 9-17 * * 1-5", "Every 15 minutes, during hours 9 through 17, on Monday through Friday"},
+		{"5 * * * *", "At minute 5 of every hour"},
+		{"0 */2 * * *", "At minute 0, every 2 hours"},
+		{"* * * * *", "Every minute"},
+		{"0 9 * * 7", "At 09:00, on Sunday"},
+		{"@daily", "At 00:00 every day"},
+		{"@every 1h30m", "Every 1h30m"},
+		{"CRON_TZ=Europe/Paris 0 9 * * *", "At 09:00, every day (Europe/Paris)"},
+	}
+
+	for _, tt := range tests {
+		if got := describeCron(tt.expr); got != tt.want {
+			t.Errorf("describeCron(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCronPreview(t *testing.T) {
+	app := newTestApp(t)
+	viewer := &backend.User{Login: "viewer", Role: roleViewer}
+
+	resp := callResource(t, app, viewer, http.MethodGet, "/cron/preview?expr=0+9+*+*+1-5&tz=America/New_York&count=3", "")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+
+	var preview CronPreview
+	if err := json.Unmarshal(resp.Body, &preview); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if preview.Timezone != "America/New_York" || preview.Description != "At 09:00, on Monday through Friday" {
+		t.Errorf("Unexpected preview: %+v", preview)
+	}
+	if len(preview.NextRuns) != 3 {
+		t.Fatalf("Expected 3 fire times, got %d", len(preview.NextRuns))
+	}
+
+	ny, _ := time.LoadLocation("America/New_York")
+	for i, next := range preview.NextRuns {
+		local := next.In(ny)
+		if local.Hour() != 9 || local.Minute() != 0 || local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+			t.Errorf("Unexpected fire time %v", local)
+		}
+		if i > 0 && !next.After(preview.NextRuns[i-1]) {
+			t.Errorf("Expected fire times in order, got %v", preview.NextRuns)
+		}
+	}
+
+	for _, query := range []string{"", "?expr=61+*+*+*+*", "?expr=0+9+*+*+*&tz=Mars/Olympus", "?expr=0+9+*+*+*&count=0", "?expr=0+9+*+*+*&count=1000"} {
+		resp := callResource(t, app, viewer, http.MethodGet, "/cron/preview"+query, "")
+		if resp.Status != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %q, got %d", query, resp.Status)
+		}
+	}
+}
+
+func TestListJobsIncludesNextRun(t *testing.T) {
+	app := newTestApp(t)
+	app.scheduler.Start()
+	job := Job{ID: "daily", Cron: "0 9 * * *"}
+	app.jobs[job.ID] = job
+	if err := app.scheduleJob(job); err != nil {
+		t.Fatalf("Failed to schedule job: %v", err)
+	}
+	app.jobs["unscheduled"] = Job{ID: "unscheduled", Cron: "0 9 * * *"}
+
+	resp := callResource(t, app, &backend.User{Login: "viewer", Role: roleViewer}, http.MethodGet, "/jobs", "")
+
+	var jobs []struct {
+		ID      string     `json:"id"`
+		NextRun *time.Time `json:"nextRun"`
+		PrevRun *time.Time `json:"prevRun"`
+	}
+	if err := json.Unmarshal(resp.Body, &jobs); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+
+	for _, job := range jobs {
+		switch job.ID {
+		case "daily":
+			if job.NextRun == nil || !job.NextRun.After(time.Now()) || job.NextRun.Minute() != 0 {
+				t.Errorf("Expected a next run for the scheduled job, got %v", job.NextRun)
+			}
+			if job.PrevRun != nil {
+				t.Errorf("Expected no previous run before the job fired, got %v", job.PrevRun)
+			}
+		case "unscheduled":
+			if job.NextRun != nil {
+				t.Errorf("Expected no next run for an unscheduled job, got %v", job.NextRun)
+			}
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package plugin
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// defaultDownloadLinkTTL is how long download links stay valid when the configuration sets no duration
+const defaultDownloadLinkTTL = 7 * 24 * time.Hour
+
+// How reports reach recipients
+const (
+	deliveryAttachment = "attachment" // attached to, or embedded in, the email
+	deliveryLink       = "link"       // archived, with a download link in the email
+)
+
+// LinkOptions configures the download links sent to the recipients of a job
+type LinkOptions struct {
+	Hours     int  `json:"hours,omitempty"`     // How long links stay valid, default: downloadLinkHours of the configuration
+	SingleUse bool `json:"singleUse,omitempty"` // Each link downloads the file once; recipients then get their own links
+}
+
+// DownloadLink is a signed link to a report file archived by a run
+type DownloadLink struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	SingleUse bool      `json:"singleUse,omitempty"`
+}
+
+// reportFile is a file of a report delivered to recipients
+type reportFile struct {
+	name string
+	data []byte
+}
+
+// validateDelivery checks the job's delivery mode and link options
+func (job Job) validateDelivery() error {
+	switch job.Delivery {
+	case "", deliveryAttachment, deliveryLink:
+	default:
+		return fmt.Errorf("invalid delivery %q: must be %s or %s", job.Delivery, deliveryAttachment, deliveryLink)
+	}
+	if job.Links != nil && job.Links.Hours < 0 {
+		return fmt.Errorf("invalid links hours %d: must not be negative", job.Links.Hours)
+	}
+	return nil
+}
+
+// downloadLinkKey derives the key signing download links from the secret key, so that links
+// cannot be forged without access to the plugin's data
+func (app *App) downloadLinkKey() ([]byte, error) {
+	key, err := app.secretKey()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("download-links"))
+	return mac.Sum(nil), nil
+}
+
+// signDownload signs the name of a report file of a run, the expiry time of its link and, for
+// single-use links, the nonce recorded when the link is used
+func signDownload(key []byte, runID, name string, expires int64, nonce string) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s/%s\n%d", runID, name, expires)
+	if nonce != "" {
+		fmt.Fprintf(mac, "\n%s", nonce)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// downloadLinkTTL returns how long download links stay valid
+func (app *App) downloadLinkTTL() time.Duration {
+	app.configMu.RLock()
+	defer app.configMu.RUnlock()
+
+	if app.config.DownloadLinkHours > 0 {
+		return time.Duration(app.config.DownloadLinkHours) * time.Hour
+	}
+	return defaultDownloadLinkTTL
+}
+
+// linkTTL returns how long the download links sent to the job's recipients stay valid
+func (app *App) linkTTL(job Job) time.Duration {
+	if job.Links != nil && job.Links.Hours > 0 {
+		return time.Duration(job.Links.Hours) * time.Hour
+	}
+	return app.downloadLinkTTL()
+}
+
+// downloadURL returns the signed link to a report file archived by a run, valid until expires.
+// Single-use links carry a random nonce, so that each of them can be used once.
+func (app *App) downloadURL(runID, name string, expires time.Time, singleUse bool) (string, error) {
+	key, err := app.downloadLinkKey()
+	if err != nil {
+		return "", err
+	}
+
+	app.configMu.RLock()
+	grafanaURL := strings.TrimSuffix(app.config.GrafanaURL, "/")
+	app.configMu.RUnlock()
+
+	query := url.Values{"expires": {strconv.FormatInt(expires.Unix(), 10)}}
+	nonce := ""
+	if singleUse {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return "", fmt.Errorf("failed to generate link nonce: %w", err)
+		}
+		nonce = hex.EncodeToString(random)
+		query.Set("once", nonce)
+	}
+	query.Set("signature", signDownload(key, runID, name, expires.Unix(), nonce))
+	return fmt.Sprintf("%s/api/plugins/%s/resources/reports/%s/%s?%s", grafanaURL, pluginID, runID, name, query.Encode()), nil
+}
+
+// sendDownloadLinks archives report files and emails links to them instead of the files, either
+// because the job delivers links or because the files are too large to be emailed. Single-use links
+// are sent to each recipient separately, so that every recipient can use their own.
+func (app *App) sendDownloadLinks(ctx context.Context, sender *EmailSender, job Job, recipients []string, files []reportFile) error {
+	runID := archiveRunID(ctx)
+	loc, err := job.location()
+	if err != nil {
+		loc = time.UTC
+	}
+	now := time.Now()
+	expires := now.Add(app.linkTTL(job))
+	singleUse := job.Links != nil && job.Links.SingleUse
+
+	stored := make([]string, len(files))
+	for i, file := range files {
+		if stored[i], err = app.storeReport(job.ID, runID, file.name, file.data, now); err != nil {
+			return err
+		}
+	}
+	// The files are removed once the links have expired
+	if err := app.extendLinksExpiry(runID, expires); err != nil {
+		return err
+	}
+
+	notice := "Download the report before %s:\n"
+	if job.Delivery != deliveryLink {
+		notice = "The report is too large to be sent by email. Download it before %s:\n"
+	}
+	groups := [][]string{recipients}
+	if singleUse {
+		groups = make([][]string, len(recipients))
+		for i, recipient := range recipients {
+			groups[i] = []string{recipient}
+		}
+	}
+
+	log.DefaultLogger.Info("Sending download links instead of the report", "id", job.ID, "run", runID, "files", len(files), "singleUse", singleUse)
+	for _, group := range groups {
+		var body strings.Builder
+		body.WriteString(job.Body)
+		body.WriteString("\n\n")
+		fmt.Fprintf(&body, notice, expires.In(loc).Format("2006-01-02 15:04 MST"))
+		for i, file := range files {
+			link, err := app.downloadURL(runID, stored[i], expires, singleUse)
+			if err != nil {
+				return fmt.Errorf("failed to sign download link: %w", err)
+			}
+			fmt.Fprintf(&body, "\n%s (%.1f MB): %s\n", stored[i], float64(len(file.data))/(1<<20), link)
+		}
+		if err := sender.Send(group, job.Subject, body.String(), nil, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// useLink records the use of a single-use download link, reporting false if it was already used.
+// Nonces are kept until their link expires.
+func (app *App) useLink(nonce string, expires int64) (bool, error) {
+	app.usedLinksMu.Lock()
+	defer app.usedLinksMu.Unlock()
+
+	used := make(map[string]int64)
+	data, err := os.ReadFile(app.usedLinksFile)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read used links file: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &used); err != nil {
+			return false, fmt.Errorf("failed to parse used links file: %w", err)
+		}
+	}
+	if _, ok := used[nonce]; ok {
+		return false, nil
+	}
+
+	now := time.Now().Unix()
+	for n, linkExpires := range used {
+		if linkExpires < now {
+			delete(used, n)
+		}
+	}
+	used[nonce] = expires
+
+	if data, err = json.Marshal(used); err != nil {
+		return false, fmt.Errorf("failed to marshal used links: %w", err)
+	}
+	if err := os.WriteFile(app.usedLinksFile, data, 0644); err != nil {
+		return false, fmt.Errorf("failed to write used links file: %w", err)
+	}
+	return true, nil
+}
+
+// confirmDownloadPage asks the user to confirm the download of a file through a single-use link
+const confirmDownloadPage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Download %[1]s</title></head>
+<body style="font-family: sans-serif; margin: 40px;">
+    <p>This link downloads <strong>%[1]s</strong> once.</p>
+    <form method="post"><button type="submit">Download</button></form>
+</body>
+</html>
+`
+
+// handleReportDownload serves an archived report file through a signed link:
+// GET /reports/{runId}/{name}?expires=...[&once=...]&signature=...
+//
+// Single-use links are only used by a POST. A GET shows a page that confirms the download with
+// a POST, so that mail scanners fetching the links of incoming emails do not use them up.
+func (app *App) handleReportDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	runID, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/reports/"), "/")
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if !ok || err != nil {
+		http.Error(w, "Invalid download link", http.StatusForbidden)
+		return
+	}
+
+	key, err := app.downloadLinkKey()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to check download link: %v", err), http.StatusInternalServerError)
+		return
+	}
+	nonce := r.URL.Query().Get("once")
+	if !hmac.Equal([]byte(signDownload(key, runID, name, expires, nonce)), []byte(r.URL.Query().Get("signature"))) {
+		http.Error(w, "Invalid download link", http.StatusForbidden)
+		return
+	}
+	if time.Now().Unix() > expires {
+		http.Error(w, "Download link expired", http.StatusGone)
+		return
+	}
+
+	path, err := app.findReport(runID, name)
+	if err != nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if nonce != "" && r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, confirmDownloadPage, html.EscapeString(name))
+		return
+	}
+	if nonce != "" {
+		fresh, err := app.useLink(nonce, expires)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to check download link: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !fresh {
+			http.Error(w, "Download link already used", http.StatusGone)
+			return
+		}
+	}
+	serveReport(w, r, path)
+}
+
+// handleRunFiles lists the report files a run archived: GET /runs/{id}/files
+func (app *App) handleRunFiles(w http.ResponseWriter, r *http.Request, runID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobDir, files, err := app.runFiles(runID)
+	if err != nil {
+		http.Error(w, "Run has no archived reports", http.StatusNotFound)
+		return
+	}
+	if !app.canManageArchive(requestUser(r), jobDir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
+}
+
+// handleRunLinks creates signed download links to the report files a run archived, to share them
+// in chat messages or elsewhere: POST /runs/{id}/links with the files (default: all), how long the
+// links stay valid and whether they can be used once
+func (app *App) handleRunLinks(w http.ResponseWriter, r *http.Request, runID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Files     []string `json:"files,omitempty"`
+		Hours     int      `json:"hours,omitempty"`
+		SingleUse bool     `json:"singleUse,omitempty"`
+	}
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Hours < 0 {
+		http.Error(w, "hours must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	jobDir, files, err := app.runFiles(runID)
+	if err != nil {
+		http.Error(w, "Run has no archived reports", http.StatusNotFound)
+		return
+	}
+	if !app.canManageArchive(requestUser(r), jobDir) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if len(req.Files) > 0 {
+		archived := make(map[string]ArchivedFile, len(files))
+		for _, file := range files {
+			archived[file.Name] = file
+		}
+		selected := make([]ArchivedFile, 0, len(req.Files))
+		for _, name := range req.Files {
+			file, ok := archived[name]
+			if !ok {
+				http.Error(w, fmt.Sprintf("Run has no archived report %s", name), http.StatusNotFound)
+				return
+			}
+			selected = append(selected, file)
+		}
+		files = selected
+	}
+
+	ttl := app.downloadLinkTTL()
+	if req.Hours > 0 {
+		ttl = time.Duration(req.Hours) * time.Hour
+	}
+	expires := time.Now().Add(ttl)
+
+	links := make([]DownloadLink, 0, len(files))
+	for _, file := range files {
+		link, err := app.downloadURL(runID, file.Name, expires, req.SingleUse)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to sign download link: %v", err), http.StatusInternalServerError)
+			return
+		}
+		links = append(links, DownloadLink{Name: file.Name, Size: file.Size, URL: link, ExpiresAt: expires.UTC().Truncate(time.Second), SingleUse: req.SingleUse})
+	}
+	if err := app.extendLinksExpiry(runID, expires); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.DefaultLogger.Info("Created download links", "run", runID, "files", len(links), "singleUse", req.SingleUse)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(links)
+}
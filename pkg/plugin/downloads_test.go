@@ -0,0 +1,331 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// downloadPath returns the resource path and query of a download link
+func downloadPath(t *testing.T, link string) string {
+	t.Helper()
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, path, ok := strings.Cut(parsed.Path, "/resources")
+	if !ok {
+		t.Fatalf("Expected a plugin resource link, got %s", link)
+	}
+	return path + "?" + parsed.RawQuery
+}
+
+func TestReportDownload(t *testing.T) {
+	app := newTestApp(t)
+	app.config.GrafanaURL = "https://grafana.example.com/"
+	viewer := &backend.User{Login: "viewer", Role: roleViewer}
+
+	if _, err := app.storeReport("ops", "run-1", "report.pdf", []byte("%PDF-1.3 report"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := app.downloadURL("run-1", "report.pdf", time.Now().Add(time.Hour), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(link, "https://grafana.example.com/api/plugins/progressio-grafanareporter-app/resources/reports/run-1/report.pdf?") {
+		t.Errorf("Unexpected link %s", link)
+	}
+	resp := callResource(t, app, viewer, http.MethodGet, downloadPath(t, link), "")
+	if resp.Status != http.StatusOK || string(resp.Body) != "%PDF-1.3 report" {
+		t.Fatalf("Expected the report, got %d: %s", resp.Status, resp.Body)
+	}
+
+	// The signature covers the file and the expiry time
+	tampered := strings.Replace(downloadPath(t, link), "report.pdf", "other.pdf", 1)
+	if resp = callResource(t, app, viewer, http.MethodGet, tampered, ""); resp.Status != http.StatusForbidden {
+		t.Errorf("Expected a link to another file to be rejected, got %d", resp.Status)
+	}
+	extended := regexp.MustCompile(`expires=\d+`).ReplaceAllString(downloadPath(t, link), "expires="+strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10))
+	if resp = callResource(t, app, viewer, http.MethodGet, extended, ""); resp.Status != http.StatusForbidden {
+		t.Errorf("Expected a link with another expiry to be rejected, got %d", resp.Status)
+	}
+
+	expired, _ := app.downloadURL("run-1", "report.pdf", time.Now().Add(-time.Minute), false)
+	if resp = callResource(t, app, viewer, http.MethodGet, downloadPath(t, expired), ""); resp.Status != http.StatusGone {
+		t.Errorf("Expected an expired link to be rejected, got %d", resp.Status)
+	}
+	missing, _ := app.downloadURL("run-2", "report.pdf", time.Now().Add(time.Hour), false)
+	if resp = callResource(t, app, viewer, http.MethodGet, downloadPath(t, missing), ""); resp.Status != http.StatusNotFound {
+		t.Errorf("Expected a link to a missing report to fail, got %d", resp.Status)
+	}
+}
+
+func TestExecuteJobSendsDownloadLink(t *testing.T) {
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testPNG(t, 400, 400, color.White))
+	}))
+	defer grafana.Close()
+	smtpServer := startFakeSMTP(t, fmt.Sprintf("SIZE %d", 4000))
+	port, _ := strconv.Atoi(smtpServer.port)
+
+	app := newTestApp(t)
+	app.config = Config{GrafanaURL: grafana.URL, SMTPHost: smtpServer.host, SMTPPort: port, SMTPFrom: "reports@example.com", DownloadLinkHours: 24}
+	job := Job{ID: "ops", DashboardUID: "abc", Format: "pdf", Recipients: []string{"ops@example.com"}, Body: "Weekly report"}
+
+	run := app.runJob(context.Background(), job, triggerManual)
+	if run.Status != runSucceeded {
+		t.Fatalf("Expected the run to succeed, got %s: %s", run.Status, run.Error)
+	}
+
+	messages := smtpServer.received()
+	if len(messages) != 1 {
+		t.Fatalf("Expected one email, got %d", len(messages))
+	}
+	if strings.Contains(messages[0], "application/pdf") {
+		t.Error("Expected the report not to be attached")
+	}
+	link := regexp.MustCompile(`https?://\S+/reports/` + run.ID + `/report-\S+\.pdf\?\S+`).FindString(messages[0])
+	if link == "" {
+		t.Fatalf("Expected a download link for run %s in %s", run.ID, messages[0])
+	}
+
+	resp := callResource(t, app, &backend.User{Login: "viewer", Role: roleViewer}, http.MethodGet, downloadPath(t, link), "")
+	if resp.Status != http.StatusOK || !strings.HasPrefix(string(resp.Body), "%PDF") {
+		t.Errorf("Expected the link to download the PDF, got %d", resp.Status)
+	}
+	// The files are kept until the link expires
+	runs, _ := app.archivedRuns("ops")
+	if len(runs) != 1 || runs[0].LinksExpireAt == nil || time.Until(*runs[0].LinksExpireAt) < 23*time.Hour {
+		t.Errorf("Expected the run to record when its links expire, got %+v", runs)
+	}
+}
+
+func TestValidateDelivery(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     Job
+		wantErr bool
+	}{
+		{name: "default", job: Job{}},
+		{name: "attachment", job: Job{Delivery: "attachment"}},
+		{name: "single-use links", job: Job{Delivery: "link", Links: &LinkOptions{Hours: 24, SingleUse: true}}},
+		{name: "unknown delivery", job: Job{Delivery: "slack"}, wantErr: true},
+		{name: "negative hours", job: Job{Delivery: "link", Links: &LinkOptions{Hours: -1}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.job.validateDelivery(); (err != nil) != tt.wantErr {
+				t.Errorf("validateDelivery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSingleUseLink(t *testing.T) {
+	app := newTestApp(t)
+	viewer := &backend.User{Login: "viewer", Role: roleViewer}
+	if _, err := app.storeReport("ops", "run-1", "report.pdf", []byte("%PDF-1.3 report"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := app.downloadURL("run-1", "report.pdf", time.Now().Add(time.Hour), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := app.downloadURL("run-1", "report.pdf", time.Now().Add(time.Hour), true)
+
+	// The nonce is part of the signature
+	forged := regexp.MustCompile(`once=[0-9a-f]+`).ReplaceAllString(downloadPath(t, link), "once=0000")
+	if resp := callResource(t, app, viewer, http.MethodGet, forged, ""); resp.Status != http.StatusForbidden {
+		t.Errorf("Expected a link with another nonce to be rejected, got %d", resp.Status)
+	}
+
+	// Opening the link, as mail scanners do, only shows a confirmation page
+	for i := 0; i < 2; i++ {
+		resp := callResource(t, app, viewer, http.MethodGet, downloadPath(t, link), "")
+		if resp.Status != http.StatusOK || !strings.Contains(string(resp.Body), `<form method="post">`) {
+			t.Fatalf("Expected a confirmation page, got %d: %s", resp.Status, resp.Body)
+		}
+	}
+
+	if resp := callResource(t, app, viewer, http.MethodPost, downloadPath(t, link), ""); resp.Status != http.StatusOK || string(resp.Body) != "%PDF-1.3 report" {
+		t.Fatalf("Expected the first download to succeed, got %d: %s", resp.Status, resp.Body)
+	}
+	if resp := callResource(t, app, viewer, http.MethodPost, downloadPath(t, link), ""); resp.Status != http.StatusGone {
+		t.Errorf("Expected the second download to be rejected, got %d", resp.Status)
+	}
+	if resp := callResource(t, app, viewer, http.MethodPost, downloadPath(t, other), ""); resp.Status != http.StatusOK {
+		t.Errorf("Expected another single-use link to the same file to work, got %d", resp.Status)
+	}
+}
+
+func TestRunLinksAPI(t *testing.T) {
+	app := newTestApp(t)
+	app.config.GrafanaURL = "https://grafana.example.com"
+	app.jobs["ops"] = Job{ID: "ops", Cron: "0 9 * * *", Owner: "editor"}
+	editor := &backend.User{Login: "editor", Role: roleEditor}
+	now := time.Now()
+	app.storeReport("ops", "run-1", "report-1.png", []byte("current"), now)
+	app.storeReport("ops", "run-1", "report-2.png", []byte("previous"), now)
+	app.storeReport("gone", "run-2", "report.png", []byte("old"), now)
+
+	resp := callResource(t, app, editor, http.MethodGet, "/runs/run-1/files", "")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+	var files []ArchivedFile
+	json.Unmarshal(resp.Body, &files)
+	if len(files) != 2 || files[0].Name != "report-1.png" || files[0].Size != 7 {
+		t.Errorf("Unexpected files %+v", files)
+	}
+
+	resp = callResource(t, app, editor, http.MethodPost, "/runs/run-1/links", `{"files": ["report-2.png"], "hours": 2, "singleUse": true}`)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+	var links []DownloadLink
+	json.Unmarshal(resp.Body, &links)
+	if len(links) != 1 || links[0].Name != "report-2.png" || !links[0].SingleUse {
+		t.Fatalf("Unexpected links %+v", links)
+	}
+	if ttl := time.Until(links[0].ExpiresAt); ttl < time.Hour || ttl > 2*time.Hour {
+		t.Errorf("Expected links valid for 2 hours, got %s", ttl)
+	}
+	viewer := &backend.User{Login: "viewer", Role: roleViewer}
+	download := callResource(t, app, viewer, http.MethodPost, downloadPath(t, links[0].URL), "")
+	if download.Status != http.StatusOK || string(download.Body) != "previous" {
+		t.Errorf("Expected the link to download the file, got %d", download.Status)
+	}
+
+	// Links to every file by default
+	resp = callResource(t, app, editor, http.MethodPost, "/runs/run-1/links", "")
+	links = nil
+	json.Unmarshal(resp.Body, &links)
+	if resp.Status != http.StatusOK || len(links) != 2 || links[0].SingleUse {
+		t.Errorf("Expected links to both files, got %d: %s", resp.Status, resp.Body)
+	}
+
+	tests := []struct {
+		name   string
+		user   *backend.User
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "other editor", user: &backend.User{Login: "other", Role: roleEditor}, method: http.MethodPost, path: "/runs/run-1/links", status: http.StatusForbidden},
+		{name: "unknown file", user: editor, method: http.MethodPost, path: "/runs/run-1/links", body: `{"files": ["report.pdf"]}`, status: http.StatusNotFound},
+		{name: "unknown run", user: editor, method: http.MethodGet, path: "/runs/run-3/files", status: http.StatusNotFound},
+		{name: "negative hours", user: editor, method: http.MethodPost, path: "/runs/run-1/links", body: `{"hours": -1}`, status: http.StatusBadRequest},
+		{name: "deleted job", user: editor, method: http.MethodGet, path: "/runs/run-2/files", status: http.StatusForbidden},
+		{name: "deleted job as admin", user: &backend.User{Login: "admin", Role: roleAdmin}, method: http.MethodGet, path: "/runs/run-2/files", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := callResource(t, app, tt.user, tt.method, tt.path, tt.body); resp.Status != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, resp.Status, resp.Body)
+			}
+		})
+	}
+}
+
+func TestExecuteJobDeliversLinks(t *testing.T) {
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testPNG(t, 100, 100, color.White))
+	}))
+	defer grafana.Close()
+	smtpServer := startFakeSMTP(t)
+	port, _ := strconv.Atoi(smtpServer.port)
+
+	app := newTestApp(t)
+	app.config = Config{GrafanaURL: grafana.URL, SMTPHost: smtpServer.host, SMTPPort: port, SMTPFrom: "reports@example.com"}
+	job := Job{
+		ID:           "ops",
+		DashboardUID: "abc",
+		Format:       "png",
+		Recipients:   []string{"alice@example.com", "bob@example.com"},
+		Delivery:     "link",
+		Links:        &LinkOptions{Hours: 1, SingleUse: true},
+	}
+
+	run := app.runJob(context.Background(), job, triggerManual)
+	if run.Status != runSucceeded {
+		t.Fatalf("Expected the run to succeed, got %s: %s", run.Status, run.Error)
+	}
+
+	// Each recipient gets their own single-use link to the same file
+	messages := smtpServer.received()
+	if len(messages) != 2 {
+		t.Fatalf("Expected one email per recipient, got %d", len(messages))
+	}
+	pattern := regexp.MustCompile(`https?://\S+/reports/` + run.ID + `/report-\S+\.png\?\S+`)
+	links := make([]string, len(messages))
+	for i, message := range messages {
+		if strings.Contains(message, "image/png") || strings.Contains(message, "too large") {
+			t.Error("Expected a link instead of the report")
+		}
+		if links[i] = pattern.FindString(message); links[i] == "" {
+			t.Fatalf("Expected a download link for run %s in %s", run.ID, message)
+		}
+	}
+	if links[0] == links[1] {
+		t.Error("Expected different links for each recipient")
+	}
+	if _, files, err := app.runFiles(run.ID); err != nil || len(files) != 1 {
+		t.Errorf("Expected the report to be archived once, got %v, %v", files, err)
+	}
+}
+
+func TestExpiredDownloadsRemoved(t *testing.T) {
+	app := newTestApp(t)
+	app.jobs["archived"] = Job{ID: "archived", Archive: &ArchiveOptions{Enabled: true}}
+	now := time.Now()
+	for _, run := range []struct{ job, id string }{{"ops", "run-1"}, {"ops", "run-2"}, {"archived", "run-3"}, {"ops", "run-4"}} {
+		if _, err := app.storeReport(run.job, run.id, "report.pdf", []byte("%PDF"), now.Add(-48*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app.extendLinksExpiry("run-1", now.Add(-time.Minute))
+	app.extendLinksExpiry("run-2", now.Add(-time.Minute))
+	app.extendLinksExpiry("run-3", now.Add(-time.Minute))
+
+	// Links created later keep the files, and the expiry is never brought forward
+	app.extendLinksExpiry("run-2", now.Add(time.Hour))
+	app.extendLinksExpiry("run-2", now.Add(time.Minute))
+
+	_, files, err := app.runFiles("run-2")
+	if err != nil || len(files) != 1 || files[0].Name != "report.pdf" {
+		t.Errorf("Expected only the report to be listed, got %+v, %v", files, err)
+	}
+
+	app.enforceRetention(now)
+
+	if _, err := app.findRunDir("run-1"); err == nil {
+		t.Error("Expected the files of expired links to be removed")
+	}
+	runs, _ := app.archivedRuns("ops")
+	if len(runs) != 2 || runs[0].RunID != "run-4" && runs[1].RunID != "run-4" {
+		t.Fatalf("Expected runs with valid links or without links to be kept, got %+v", runs)
+	}
+	for _, run := range runs {
+		if run.RunID == "run-2" && (run.LinksExpireAt == nil || run.LinksExpireAt.Before(now.Add(59*time.Minute))) {
+			t.Errorf("Expected the links of run-2 to expire in an hour, got %v", run.LinksExpireAt)
+		}
+	}
+	if _, err := app.findRunDir("run-3"); err != nil {
+		t.Error("Expected the runs of a job archiving every run to be left to its retention rules")
+	}
+}
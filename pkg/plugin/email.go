@@ -2,11 +2,16 @@ package plugin
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"fmt"
+	"html"
 	"mime/multipart"
+	"net"
 	"net/smtp"
 	"net/textproto"
+	"strconv"
 	"strings"
 )
 
@@ -22,6 +27,21 @@ type EmailSender struct {
 	user string
 	pass string
 	from string
+	ctx  context.Context
+	
+	// maxSize is the largest message to send, in addition to the SIZE limit of the server; 0 for none
+	maxSize int64
+}
+
+// MessageTooLargeError reports a message larger than the SMTP server or the configuration accepts.
+// It is returned before the message is sent.
+type MessageTooLargeError struct {
+	Size  int64
+	Limit int64
+}
+
+func (e *MessageTooLargeError) Error() string {
+	return fmt.Sprintf("message of %d bytes exceeds the limit of %d bytes", e.Size, e.Limit)
 }
 
 // NewEmailSender creates a new email sender
@@ -35,6 +55,29 @@ func NewEmailSender(host, port, user, pass, from string) *EmailSender {
 	}
 }
 
+// WithContext returns a copy of the sender whose SMTP sessions are bound to ctx:
+// they are aborted when ctx is cancelled or its deadline passes
+func (s *EmailSender) WithContext(ctx context.Context) *EmailSender {
+	copied := *s
+	copied.ctx = ctx
+	return &copied
+}
+
+// WithMaxMessageSize returns a copy of the sender that refuses messages larger than limit bytes,
+// or than the SIZE limit advertised by the server when it is lower
+func (s *EmailSender) WithMaxMessageSize(limit int64) *EmailSender {
+	copied := *s
+	copied.maxSize = limit
+	return &copied
+}
+
+func (s *EmailSender) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
 // Send sends an email with an attachment
 func (s *EmailSender) Send(to []string, subject, body string, attachment []byte, filename string) error {
 	// Create message
@@ -67,6 +110,10 @@ func (s *EmailSender) Send(to []string, subject, body string, attachment []byte,
 		contentType := "application/octet-stream"
 		if strings.HasSuffix(filename, ".png") {
 			contentType = "image/png"
+		} else if strings.HasSuffix(filename, ".jpg") {
+			contentType = "image/jpeg"
+		} else if strings.HasSuffix(filename, ".webp") {
+			contentType = "image/webp"
 		} else if strings.HasSuffix(filename, ".pdf") {
 			contentType = "application/pdf"
 		}
@@ -94,20 +141,42 @@ func (s *EmailSender) Send(to []string, subject, body string, attachment []byte,
 	
 	writer.Close()
 	
-	// Connect to SMTP server
-	addr := fmt.Sprintf("%s:%s", s.host, s.port)
-	auth := smtp.PlainAuth("", s.user, s.pass, s.host)
-	
 	// Send email
-	if err := smtp.SendMail(addr, auth, s.from, to, buf.Bytes()); err != nil {
+	if err := s.deliver(to, buf.Bytes()); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 	
 	return nil
 }
 
+// InlineImage is an image embedded in an HTML email, with an optional caption shown above it
+type InlineImage struct {
+	Data        []byte
+	ContentType string
+	Caption     string
+}
+
 // SendHTML sends an email with HTML body and embedded image (fully offline, no external links)
 func (s *EmailSender) SendHTML(to []string, subject, body string, imageData []byte, imageFormat string, dashboardURL string) error {
+	// Determine content type based on format
+	contentType := "image/png"
+	if imageFormat == "pdf" {
+		contentType = "application/pdf"
+	} else if imageFormat == "jpeg" {
+		contentType = "image/jpeg"
+	} else if imageFormat == "webp" {
+		contentType = "image/webp"
+	}
+	
+	var images []InlineImage
+	if len(imageData) > 0 {
+		images = append(images, InlineImage{Data: imageData, ContentType: contentType})
+	}
+	return s.SendHTMLImages(to, subject, body, images)
+}
+
+// SendHTMLImages sends an email with HTML body and embedded images, one below the other
+func (s *EmailSender) SendHTMLImages(to []string, subject, body string, images []InlineImage) error {
 	// Create message
 	var buf bytes.Buffer
 	
@@ -211,6 +280,12 @@ func (s *EmailSender) SendHTML(to []string, subject, body string, imageData []by
             display: block;
             margin: 0 auto;
         }
+        .report-caption {
+            margin: 0 0 12px 0;
+            font-size: 15px;
+            font-weight: 600;
+            color: #333;
+        }
         .info-box {
             padding: 15px;
             background-color: #e8f5e9;
@@ -246,10 +321,7 @@ func (s *EmailSender) SendHTML(to []string, subject, body string, imageData []by
         <div class="content">
             <p>%s</p>
         </div>
-        <div class="report-section">
-            <img src="cid:report-image" alt="Grafana Report Dashboard" class="report-image" />
-        </div>
-        <div class="info-box">
+%s        <div class="info-box">
             <strong>📋 Report Information</strong>
             This is a static snapshot of your Grafana dashboard, captured at the moment of generation. All content is embedded within this email for offline viewing.
         </div>
@@ -259,22 +331,16 @@ func (s *EmailSender) SendHTML(to []string, subject, body string, imageData []by
         </div>
     </div>
 </body>
-</html>`, strings.ReplaceAll(body, "\n", "<br>"))
+</html>`, strings.ReplaceAll(body, "\n", "<br>"), reportSections(images))
 	
 	htmlPart.Write([]byte(htmlContent))
 	
-	// Write embedded image part
-	if len(imageData) > 0 {
-		// Determine content type based on format
-		contentType := "image/png"
-		if imageFormat == "pdf" {
-			contentType = "application/pdf"
-		}
-		
+	// Write embedded image parts
+	for i, image := range images {
 		imagePart, err := innerWriter.CreatePart(textproto.MIMEHeader{
-			"Content-Type":              []string{contentType},
+			"Content-Type":              []string{image.ContentType},
 			"Content-Transfer-Encoding": []string{"base64"},
-			"Content-ID":                []string{"<report-image>"},
+			"Content-ID":                []string{"<" + inlineImageID(i) + ">"},
 			"Content-Disposition":       []string{"inline"},
 		})
 		if err != nil {
@@ -282,7 +348,7 @@ func (s *EmailSender) SendHTML(to []string, subject, body string, imageData []by
 		}
 		
 		// Encode image as base64
-		encoded := base64.StdEncoding.EncodeToString(imageData)
+		encoded := base64.StdEncoding.EncodeToString(image.Data)
 		// Write in 76-character lines
 		for i := 0; i < len(encoded); i += 76 {
 			end := i + 76
@@ -298,14 +364,152 @@ func (s *EmailSender) SendHTML(to []string, subject, body string, imageData []by
 	
 	outerWriter.Close()
 	
-	// Connect to SMTP server
-	addr := fmt.Sprintf("%s:%s", s.host, s.port)
-	auth := smtp.PlainAuth("", s.user, s.pass, s.host)
-	
 	// Send email
-	if err := smtp.SendMail(addr, auth, s.from, to, buf.Bytes()); err != nil {
+	if err := s.deliver(to, buf.Bytes()); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 	
 	return nil
 }
+
+// inlineImageID returns the Content-ID of the i-th embedded image; the first one keeps the historical ID
+func inlineImageID(i int) string {
+	if i == 0 {
+		return "report-image"
+	}
+	return fmt.Sprintf("report-image-%d", i+1)
+}
+
+// reportSections returns the HTML sections showing the embedded images
+func reportSections(images []InlineImage) string {
+	var sections strings.Builder
+	for i, image := range images {
+		sections.WriteString("        <div class=\"report-section\">\n")
+		if image.Caption != "" {
+			sections.WriteString(fmt.Sprintf("            <p class=\"report-caption\">%s</p>\n", html.EscapeString(image.Caption)))
+		}
+		sections.WriteString(fmt.Sprintf("            <img src=\"cid:%s\" alt=\"Grafana Report Dashboard\" class=\"report-image\" />\n", inlineImageID(i)))
+		sections.WriteString("        </div>\n")
+	}
+	return sections.String()
+}
+
+// Verify connects to the SMTP server, says EHLO, upgrades to TLS when offered and authenticates,
+// without sending any message
+func (s *EmailSender) Verify(ctx context.Context) error {
+	client, release, err := s.WithContext(ctx).connect()
+	if err != nil {
+		return err
+	}
+	defer release()
+	
+	return client.Quit()
+}
+
+// deliver sends a raw message to the recipients over a new SMTP session
+func (s *EmailSender) deliver(to []string, msg []byte) error {
+	client, release, err := s.connect()
+	if err != nil {
+		return err
+	}
+	defer release()
+	
+	// Refuse messages the server would reject, so that callers can send them another way
+	if limit := s.sizeLimit(client); limit > 0 && int64(len(msg)) > limit {
+		client.Quit()
+		return &MessageTooLargeError{Size: int64(len(msg)), Limit: limit}
+	}
+	
+	if err := client.Mail(s.from); err != nil {
+		return fmt.Errorf("MAIL FROM failed: %w", err)
+	}
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return fmt.Errorf("RCPT TO %s failed: %w", addr, err)
+		}
+	}
+	
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA failed: %w", err)
+	}
+	if _, err := writer.Write(msg); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finish message: %w", err)
+	}
+	
+	return client.Quit()
+}
+
+// sizeLimit returns the lowest of the configured limit and the SIZE limit advertised by the server, 0 if neither is set
+func (s *EmailSender) sizeLimit(client *smtp.Client) int64 {
+	limit := s.maxSize
+	if ok, param := client.Extension("SIZE"); ok {
+		if size, err := strconv.ParseInt(strings.TrimSpace(param), 10, 64); err == nil && size > 0 && (limit == 0 || size < limit) {
+			limit = size
+		}
+	}
+	return limit
+}
+
+// connect opens an SMTP session bound to the sender's context: it says EHLO,
+// upgrades to TLS when offered and authenticates when credentials are set. The
+// returned function ends the session and must be called once it is done.
+func (s *EmailSender) connect() (*smtp.Client, func(), error) {
+	ctx := s.context()
+	addr := fmt.Sprintf("%s:%s", s.host, s.port)
+	
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	
+	// Abort the session as soon as the context is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	
+	release := func() {
+		stop()
+		client.Close()
+	}
+	fail := func(err error) (*smtp.Client, func(), error) {
+		release()
+		if ctx.Err() != nil {
+			return nil, nil, context.Cause(ctx)
+		}
+		return nil, nil, err
+	}
+	
+	if err := client.Hello("localhost"); err != nil {
+		return fail(fmt.Errorf("EHLO failed: %w", err))
+	}
+	
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fail(fmt.Errorf("STARTTLS failed: %w", err))
+		}
+	}
+	
+	if s.user != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fail(fmt.Errorf("SMTP server does not support authentication"))
+		}
+		if err := client.Auth(smtp.PlainAuth("", s.user, s.pass, s.host)); err != nil {
+			return fail(fmt.Errorf("authentication failed: %w", err))
+		}
+	}
+	
+	return client, release, nil
+}
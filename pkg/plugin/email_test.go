@@ -1,10 +1,107 @@
 package plugin
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
 	"strings"
+	"sync"
 	"testing"
 )
 
+// fakeSMTPServer is a minimal SMTP server for tests, recording the messages it receives
+type fakeSMTPServer struct {
+	host       string
+	port       string
+	extensions []string
+
+	mu       sync.Mutex
+	messages []string
+}
+
+// startFakeSMTP starts a fake SMTP server on localhost advertising the given extra EHLO extensions
+func startFakeSMTP(t *testing.T, extensions ...string) *fakeSMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start fake SMTP server: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	server := &fakeSMTPServer{host: host, port: port, extensions: extensions}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-fake")
+			for _, ext := range s.extensions {
+				reply("250-" + ext)
+			}
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "AUTH"):
+			reply("235 Authenticated")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 Go ahead")
+			var message strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				message.WriteString(dataLine)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, message.String())
+			s.mu.Unlock()
+			reply("250 Queued")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// received returns the messages delivered so far
+func (s *fakeSMTPServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
 func TestBuildDashboardURL(t *testing.T) {
 	app := &App{
 		config: Config{
@@ -152,3 +249,54 @@ func TestEmailSenderSendHTML(t *testing.T) {
 		t.Log("Note: SendHTML would normally fail without a real SMTP server")
 	}
 }
+
+func TestEmailSenderVerify(t *testing.T) {
+	server := startFakeSMTP(t)
+
+	sender := NewEmailSender(server.host, server.port, "user", "password", "from@example.com")
+	if err := sender.Verify(context.Background()); err != nil {
+		t.Errorf("Expected SMTP verification to succeed, got %v", err)
+	}
+
+	sender = NewEmailSender("127.0.0.1", "1", "user", "password", "from@example.com")
+	if err := sender.Verify(context.Background()); err == nil {
+		t.Error("Expected SMTP verification to fail for an unreachable server")
+	}
+}
+
+func TestReportSections(t *testing.T) {
+	sections := reportSections([]InlineImage{{Caption: "Current period"}, {Caption: "Previous <1w>"}})
+	for _, want := range []string{`src="cid:report-image"`, `src="cid:report-image-2"`, "Current period", "Previous &lt;1w&gt;"} {
+		if !strings.Contains(sections, want) {
+			t.Errorf("Expected sections to contain %q, got:\n%s", want, sections)
+		}
+	}
+	if strings.Contains(reportSections([]InlineImage{{}}), "report-caption") {
+		t.Error("Expected no caption for images without one")
+	}
+}
+
+func TestDeliverRefusesOversizedMessages(t *testing.T) {
+	server := startFakeSMTP(t, "SIZE 1000")
+	attachment := make([]byte, 2000)
+
+	err := NewEmailSender(server.host, server.port, "", "", "from@example.com").Send([]string{"to@example.com"}, "Report", "", attachment, "report.pdf")
+	var tooLarge *MessageTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 1000 {
+		t.Fatalf("Expected the server's SIZE limit to be enforced, got %v", err)
+	}
+
+	// A configured limit lower than the server's applies too
+	sender := NewEmailSender(server.host, server.port, "", "", "from@example.com").WithMaxMessageSize(500)
+	err = sender.Send([]string{"to@example.com"}, "Report", "", []byte("small"), "report.pdf")
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 500 {
+		t.Fatalf("Expected the configured limit to be enforced, got %v", err)
+	}
+
+	if len(server.received()) != 0 {
+		t.Error("Expected no message to be sent")
+	}
+	if err := sender.WithMaxMessageSize(0).Send([]string{"to@example.com"}, "Report", "", []byte("small"), "report.pdf"); err != nil {
+		t.Errorf("Expected a small message to be sent, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout bounds each individual health check
+const healthCheckTimeout = 10 * time.Second
+
+// Health check statuses
+const (
+	checkOK    = "ok"
+	checkError = "error"
+)
+
+// healthCheck is the result of one dependency check, reported in the health check JSON details
+type healthCheck struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latencyMs"`
+	Message   string `json:"message,omitempty"`
+}
+
+// dependencyCheck is a named check of one dependency
+type dependencyCheck struct {
+	name string
+	fn   func(ctx context.Context, config Config) error
+}
+
+// runHealthChecks checks the scheduler, Grafana API, image renderer, SMTP server and,
+// when replicas are coordinated, the shared coordination directory concurrently
+func (app *App) runHealthChecks(ctx context.Context) []healthCheck {
+	app.configMu.RLock()
+	config := app.currentConfig()
+	app.configMu.RUnlock()
+
+	checks := []dependencyCheck{
+		{"scheduler", app.checkScheduler},
+		{"grafana", checkGrafanaAPI},
+		{"renderer", checkRenderer},
+		{"smtp", checkSMTP},
+	}
+	if app.coordinator != nil {
+		checks = append(checks, dependencyCheck{"coordination", app.checkCoordination})
+	}
+
+	results := make([]healthCheck, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, name string, fn func(context.Context, Config) error) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := fn(checkCtx, config)
+			results[i] = healthCheck{
+				Name:      name,
+				Status:    checkOK,
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				results[i].Status = checkError
+				results[i].Message = err.Error()
+			}
+		}(i, check.name, check.fn)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (app *App) checkScheduler(ctx context.Context, config Config) error {
+	if app.scheduler == nil {
+		return fmt.Errorf("scheduler is not running")
+	}
+	return nil
+}
+
+// checkGrafanaAPI verifies that the configured API key is accepted by Grafana
+func checkGrafanaAPI(ctx context.Context, config Config) error {
+	if config.GrafanaURL == "" {
+		return fmt.Errorf("Grafana URL not configured")
+	}
+	if config.GrafanaAPIKey == "" {
+		return fmt.Errorf("Grafana API key not configured")
+	}
+
+	status, body, err := grafanaGet(ctx, config, "/api/search?type=dash-db&limit=1")
+	if err != nil {
+		return err
+	}
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("Grafana rejected the API key (status %d)", status)
+	default:
+		return fmt.Errorf("Grafana API returned status %d: %s", status, body)
+	}
+}
+
+// checkRenderer verifies that an image renderer is available, either as the grafana-image-renderer
+// plugin or, failing that, by rendering a tiny image (which also covers remote rendering services)
+func checkRenderer(ctx context.Context, config Config) error {
+	if config.GrafanaURL == "" {
+		return fmt.Errorf("Grafana URL not configured")
+	}
+
+	status, _, err := grafanaGet(ctx, config, "/api/plugins/grafana-image-renderer/settings")
+	if err != nil {
+		return err
+	}
+	if status == http.StatusOK {
+		return nil
+	}
+
+	// Grafana answers with an image even for unknown dashboards when a renderer is available
+	status, body, err := grafanaGet(ctx, config, "/render/d-solo/reporter-health-check?panelId=1&width=10&height=10")
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("no image renderer available (test render returned status %d: %s)", status, body)
+	}
+	return nil
+}
+
+// checkSMTP connects and authenticates to the SMTP server
+func checkSMTP(ctx context.Context, config Config) error {
+	if config.SMTPHost == "" {
+		return fmt.Errorf("SMTP host not configured")
+	}
+
+	port := fmt.Sprintf("%d", config.SMTPPort)
+	if config.SMTPPort == 0 {
+		port = "587"
+	}
+
+	sender := NewEmailSender(config.SMTPHost, port, config.SMTPUser, config.SMTPPassword, config.SMTPFrom)
+	return sender.Verify(ctx)
+}
+
+// grafanaGet performs an authenticated GET against the Grafana API and returns the status and a short body excerpt
+func grafanaGet(ctx context.Context, config Config, path string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(config.GrafanaURL, "/")+path, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	if config.GrafanaAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+config.GrafanaAPIKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to reach Grafana: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return resp.StatusCode, string(body), nil
+}
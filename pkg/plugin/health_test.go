@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestCheckHealth(t *testing.T) {
+	rendererInstalled := true
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good-key" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/search":
+			w.Write([]byte("[]"))
+		case "/api/plugins/grafana-image-renderer/settings":
+			if !rendererInstalled {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte("{}"))
+		default:
+			http.Error(w, "No image renderer available/installed", http.StatusInternalServerError)
+		}
+	}))
+	defer grafana.Close()
+
+	smtpServer := startFakeSMTP(t)
+	port, _ := strconv.Atoi(smtpServer.port)
+
+	app := newTestApp(t)
+	app.config = Config{
+		GrafanaURL:    grafana.URL,
+		GrafanaAPIKey: "good-key",
+		SMTPHost:      smtpServer.host,
+		SMTPPort:      port,
+		SMTPUser:      "user",
+		SMTPPassword:  "password",
+	}
+
+	result, err := app.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Status != backend.HealthStatusOk {
+		t.Fatalf("Expected healthy status, got %v: %s", result.Status, result.Message)
+	}
+
+	var details struct {
+		Checks []healthCheck `json:"checks"`
+	}
+	if err := json.Unmarshal(result.JSONDetails, &details); err != nil {
+		t.Fatalf("Invalid JSON details: %v", err)
+	}
+	if len(details.Checks) != 4 {
+		t.Fatalf("Expected 4 checks, got %+v", details.Checks)
+	}
+
+	// A wrong API key and a missing renderer are reported individually
+	app.config.GrafanaAPIKey = "bad-key"
+	rendererInstalled = false
+	result, _ = app.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if result.Status != backend.HealthStatusError {
+		t.Fatalf("Expected error status, got %v", result.Status)
+	}
+	json.Unmarshal(result.JSONDetails, &details)
+	statuses := make(map[string]string)
+	for _, check := range details.Checks {
+		statuses[check.Name] = check.Status
+	}
+	want := map[string]string{"scheduler": checkOK, "grafana": checkError, "renderer": checkError, "smtp": checkOK}
+	for name, status := range want {
+		if statuses[name] != status {
+			t.Errorf("Expected %s check to be %s, got %s", name, status, statuses[name])
+		}
+	}
+}
+
+func TestCheckHealthReportsMissingSMTP(t *testing.T) {
+	app := newTestApp(t)
+	if err := checkSMTP(context.Background(), app.config); err == nil {
+		t.Error("Expected error when SMTP host is not configured")
+	}
+}
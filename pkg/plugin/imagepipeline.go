@@ -0,0 +1,420 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Image formats and watermark positions
+const (
+	imageFormatPNG  = "png"
+	imageFormatJPEG = "jpeg"
+	imageFormatWebP = "webp"
+
+	watermarkBottomRight = "bottomRight"
+	watermarkBottomLeft  = "bottomLeft"
+	watermarkTopRight    = "topRight"
+	watermarkTopLeft     = "topLeft"
+	watermarkCenter      = "center"
+
+	defaultJPEGQuality      = 85
+	defaultWatermarkOpacity = 0.5
+	watermarkLogoFraction   = 5  // Logos are scaled down to at most a fifth of the image
+	watermarkTextFraction   = 40 // Text is a fortieth of the image width high
+	minWatermarkTextSize    = 12
+)
+
+// ImageOptions controls the post-processing of rendered images, applied before they are delivered
+type ImageOptions struct {
+	Crop      *CropRegion `json:"crop,omitempty"`      // Region of the rendered image to keep
+	MaxWidth  int         `json:"maxWidth,omitempty"`  // Wider images are scaled down, keeping their aspect ratio
+	Format    string      `json:"format,omitempty"`    // png (default), jpeg or webp, for PNG and HTML reports
+	Quality   int         `json:"quality,omitempty"`   // From 1 to 100; JPEG defaults to 85, WebP to lossless
+	Watermark *Watermark  `json:"watermark,omitempty"` // Text and/or logo stamped on the image
+}
+
+// CropRegion is a rectangle of the rendered image, in pixels
+type CropRegion struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Watermark is a text and/or logo stamped on rendered images
+type Watermark struct {
+	Text     string  `json:"text,omitempty"`     // {date} is replaced by the date of the run
+	Logo     string  `json:"logo,omitempty"`     // Name of an uploaded asset, shown above the text
+	Position string  `json:"position,omitempty"` // bottomRight (default), bottomLeft, topRight, topLeft or center
+	Opacity  float64 `json:"opacity,omitempty"`  // From 0 to 1, default 0.5
+}
+
+// imageOptions returns the job's image options, or the defaults
+func (job Job) imageOptions() ImageOptions {
+	if job.Image == nil {
+		return ImageOptions{}
+	}
+	return *job.Image
+}
+
+// withImageOptions returns the job with its image options changed by update
+func (job Job) withImageOptions(update func(*ImageOptions)) Job {
+	options := job.imageOptions()
+	update(&options)
+	job.Image = &options
+	return job
+}
+
+// imageFormat returns the format PNG and HTML reports are delivered in
+func (job Job) imageFormat() string {
+	format := job.imageOptions().Format
+	if job.Format != "pdf" && (format == imageFormatJPEG || format == imageFormatWebP) {
+		return format
+	}
+	return imageFormatPNG
+}
+
+// imageExtension returns the file extension of the job's delivered images
+func (job Job) imageExtension() string {
+	if format := job.imageFormat(); format != imageFormatJPEG {
+		return format
+	}
+	return "jpg"
+}
+
+// validateImageOptions checks the job's image options
+func (job Job) validateImageOptions() error {
+	options := job.imageOptions()
+	if crop := options.Crop; crop != nil && (crop.X < 0 || crop.Y < 0 || crop.Width <= 0 || crop.Height <= 0) {
+		return fmt.Errorf("invalid crop region: x and y must not be negative, width and height must be positive")
+	}
+	if options.MaxWidth < 0 {
+		return fmt.Errorf("maxWidth must not be negative")
+	}
+	switch options.Format {
+	case "", imageFormatPNG:
+	case imageFormatJPEG, imageFormatWebP:
+		if job.Format == "pdf" {
+			return fmt.Errorf("image format %s applies to png and html reports only", options.Format)
+		}
+	default:
+		return fmt.Errorf("invalid image format %q (expected %s, %s or %s)", options.Format, imageFormatPNG, imageFormatJPEG, imageFormatWebP)
+	}
+	if options.Quality < 0 || options.Quality > 100 {
+		return fmt.Errorf("image quality must be between 1 and 100")
+	}
+
+	watermark := options.Watermark
+	if watermark == nil {
+		return nil
+	}
+	if watermark.Text == "" && watermark.Logo == "" {
+		return fmt.Errorf("watermark requires a text or a logo")
+	}
+	switch watermark.Position {
+	case "", watermarkBottomRight, watermarkBottomLeft, watermarkTopRight, watermarkTopLeft, watermarkCenter:
+	default:
+		return fmt.Errorf("invalid watermark position %q", watermark.Position)
+	}
+	if watermark.Opacity < 0 || watermark.Opacity > 1 {
+		return fmt.Errorf("watermark opacity must be between 0 and 1")
+	}
+	return nil
+}
+
+// renderImage renders the job's dashboard or panel and post-processes the image
+func (app *App) renderImage(ctx context.Context, job Job) ([]byte, error) {
+	data, err := app.renderReport(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	processed, err := app.processImage(job, data, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to process image: %w", err)
+	}
+	return processed, nil
+}
+
+// processImage crops, scales down and watermarks a rendered PNG image. Images that need none of
+// these are returned as is.
+func (app *App) processImage(job Job, data []byte, at time.Time) ([]byte, error) {
+	options := job.imageOptions()
+	if options.Crop == nil && options.MaxWidth == 0 && options.Watermark == nil {
+		return data, nil
+	}
+
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode rendered image: %w", err)
+	}
+	img := image.Image(src)
+
+	if crop := options.Crop; crop != nil {
+		region := image.Rect(crop.X, crop.Y, crop.X+crop.Width, crop.Y+crop.Height).Add(img.Bounds().Min).Intersect(img.Bounds())
+		if region.Empty() {
+			return nil, fmt.Errorf("crop region is outside the %dx%d image", img.Bounds().Dx(), img.Bounds().Dy())
+		}
+		cropped := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+		draw.Draw(cropped, cropped.Bounds(), img, region.Min, draw.Src)
+		img = cropped
+	}
+
+	if options.MaxWidth > 0 {
+		img = scaleDown(img, options.MaxWidth, img.Bounds().Dy())
+	}
+
+	if watermark := options.Watermark; watermark != nil {
+		var logo image.Image
+		if watermark.Logo != "" {
+			logo, err = app.watermarkLogo(watermark.Logo)
+			if err != nil {
+				log.DefaultLogger.Warn("Failed to read watermark logo, leaving it out", "id", job.ID, "logo", watermark.Logo, "error", err)
+			}
+		}
+		loc, err := job.location()
+		if err != nil {
+			loc = time.UTC
+		}
+		text := strings.ReplaceAll(watermark.Text, "{date}", at.In(loc).Format("2006-01-02"))
+		if img, err = stampWatermark(img, *watermark, text, logo); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeImages converts the rendered PNG images of PNG and HTML reports to the job's image format
+func (job Job) encodeImages(data []byte, periods []renderedPeriod) ([]byte, []renderedPeriod, error) {
+	var encode func([]byte) ([]byte, error)
+	switch job.imageFormat() {
+	case imageFormatJPEG:
+		encode = job.encodeJPEG
+	case imageFormatWebP:
+		encode = job.encodeWebP
+	default:
+		return data, periods, nil
+	}
+
+	encoded, err := encode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	converted := make([]renderedPeriod, len(periods))
+	for i, period := range periods {
+		converted[i] = period
+		if converted[i].data, err = encode(period.data); err != nil {
+			return nil, nil, err
+		}
+	}
+	return encoded, converted, nil
+}
+
+// encodeJPEG converts a PNG image to JPEG with the job's quality
+func (job Job) encodeJPEG(data []byte) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode rendered image: %w", err)
+	}
+	quality := job.imageOptions().Quality
+	if quality == 0 {
+		quality = defaultJPEGQuality
+	}
+
+	// JPEG has no transparency: transparent areas are shown on white
+	flattened := image.NewRGBA(img.Bounds())
+	draw.Draw(flattened, flattened.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flattened, flattened.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flattened, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode JPEG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeWebP converts a PNG image to lossless WebP. Below a quality of 100, the precision of the
+// colours is first reduced, the more so the lower the quality, so that the image compresses better.
+func (job Job) encodeWebP(data []byte) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode rendered image: %w", err)
+	}
+	if quality := job.imageOptions().Quality; quality > 0 && quality < 100 {
+		img = reduceColors(img, uint((100-quality)/20+1))
+	}
+
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, img, nil); err != nil {
+		return nil, fmt.Errorf("failed to encode WebP: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// reduceColors rounds the colour channels of img to multiples of 2^bits
+func reduceColors(img image.Image, bits uint) image.Image {
+	bounds := img.Bounds()
+	reduced := image.NewNRGBA(bounds)
+	draw.Draw(reduced, bounds, img, bounds.Min, draw.Src)
+
+	mask := uint8(1)<<bits - 1
+	for i := 0; i < len(reduced.Pix); i += 4 {
+		for c := i; c < i+3; c++ {
+			v := reduced.Pix[c]
+			if v <= 255-mask/2 {
+				v += mask / 2
+			}
+			reduced.Pix[c] = v &^ mask
+		}
+	}
+	return reduced
+}
+
+// imageContentType returns the MIME type of the job's delivered images
+func (job Job) imageContentType() string {
+	return "image/" + job.imageFormat()
+}
+
+// scaleDown scales img down to fit within maxWidth x maxHeight, keeping its aspect ratio. Smaller
+// images are returned as is.
+func scaleDown(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= maxWidth && bounds.Dy() <= maxHeight {
+		return img
+	}
+	width, height := maxWidth, bounds.Dy()*maxWidth/bounds.Dx()
+	if height > maxHeight {
+		width, height = bounds.Dx()*maxHeight/bounds.Dy(), maxHeight
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, max(width, 1), max(height, 1)))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+	return scaled
+}
+
+// watermarkLogo reads and decodes the named asset
+func (app *App) watermarkLogo(name string) (image.Image, error) {
+	data, err := app.readAsset(name)
+	if err != nil {
+		return nil, err
+	}
+	logo, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode logo: %w", err)
+	}
+	return logo, nil
+}
+
+// stampWatermark draws the logo, scaled down to a fraction of the image, above the text at the
+// watermark's position, with its opacity
+func stampWatermark(img image.Image, watermark Watermark, text string, logo image.Image) (image.Image, error) {
+	bounds := img.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), img, bounds.Min, draw.Src)
+
+	opacity := watermark.Opacity
+	if opacity == 0 {
+		opacity = defaultWatermarkOpacity
+	}
+	alpha := uint8(opacity * 255)
+
+	face, err := watermarkFace(max(bounds.Dx()/watermarkTextFraction, minWatermarkTextSize))
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	// Lay out the logo and text as a block, with a margin of one line height
+	margin := face.Metrics().Height.Ceil()
+	if logo != nil {
+		logo = scaleDown(logo, bounds.Dx()/watermarkLogoFraction, bounds.Dy()/watermarkLogoFraction)
+	}
+	var logoSize, textSize image.Point
+	if logo != nil {
+		logoSize = logo.Bounds().Size()
+	}
+	if text != "" {
+		textSize = image.Pt(font.MeasureString(face, text).Ceil(), margin)
+	}
+	block := image.Pt(max(logoSize.X, textSize.X), logoSize.Y+textSize.Y)
+	origin := watermarkOrigin(watermark.Position, canvas.Bounds(), block, margin)
+
+	if logo != nil {
+		x := origin.X + alignOffset(watermark.Position, block.X, logoSize.X)
+		rect := image.Rectangle{Min: image.Pt(x, origin.Y), Max: image.Pt(x, origin.Y).Add(logoSize)}
+		draw.DrawMask(canvas, rect, logo, logo.Bounds().Min, image.NewUniform(color.Alpha{A: alpha}), image.Point{}, draw.Over)
+	}
+	if text != "" {
+		x := origin.X + alignOffset(watermark.Position, block.X, textSize.X)
+		drawer := font.Drawer{
+			Dst:  canvas,
+			Src:  image.NewUniform(color.NRGBA{R: 128, G: 128, B: 128, A: alpha}),
+			Face: face,
+			Dot:  fixed.P(x, origin.Y+logoSize.Y+face.Metrics().Ascent.Ceil()),
+		}
+		drawer.DrawString(text)
+	}
+	return canvas, nil
+}
+
+// watermarkFace returns the Go Regular font at size pixels
+func watermarkFace(size int) (font.Face, error) {
+	ttf, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse watermark font: %w", err)
+	}
+	face, err := opentype.NewFace(ttf, &opentype.FaceOptions{Size: float64(size), DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watermark font: %w", err)
+	}
+	return face, nil
+}
+
+// watermarkOrigin returns the top-left corner of a block of the given size at the position
+func watermarkOrigin(position string, bounds image.Rectangle, size image.Point, margin int) image.Point {
+	left, top := bounds.Min.X+margin, bounds.Min.Y+margin
+	right, bottom := bounds.Max.X-margin-size.X, bounds.Max.Y-margin-size.Y
+	switch position {
+	case watermarkTopLeft:
+		return image.Pt(left, top)
+	case watermarkTopRight:
+		return image.Pt(right, top)
+	case watermarkBottomLeft:
+		return image.Pt(left, bottom)
+	case watermarkCenter:
+		return image.Pt(bounds.Min.X+(bounds.Dx()-size.X)/2, bounds.Min.Y+(bounds.Dy()-size.Y)/2)
+	default:
+		return image.Pt(right, bottom)
+	}
+}
+
+// alignOffset returns the offset of an item within the watermark block: right-aligned on the
+// right, centered in the middle and left-aligned otherwise
+func alignOffset(position string, blockWidth, width int) int {
+	switch position {
+	case watermarkTopRight, watermarkBottomRight, "":
+		return blockWidth - width
+	case watermarkCenter:
+		return (blockWidth - width) / 2
+	default:
+		return 0
+	}
+}
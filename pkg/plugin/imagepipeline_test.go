@@ -0,0 +1,243 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/image/webp"
+)
+
+func TestValidateImageOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     Job
+		wantErr bool
+	}{
+		{name: "no options", job: Job{Format: "png"}},
+		{name: "jpeg", job: Job{Format: "html", Image: &ImageOptions{Format: "jpeg", Quality: 70, MaxWidth: 1200}}},
+		{name: "crop", job: Job{Format: "png", Image: &ImageOptions{Crop: &CropRegion{X: 0, Y: 100, Width: 800, Height: 600}}}},
+		{name: "watermark", job: Job{Format: "pdf", Image: &ImageOptions{Watermark: &Watermark{Text: "CONFIDENTIAL", Position: "center", Opacity: 0.3}}}},
+		{name: "empty crop", job: Job{Format: "png", Image: &ImageOptions{Crop: &CropRegion{Width: 0, Height: 600}}}, wantErr: true},
+		{name: "negative max width", job: Job{Format: "png", Image: &ImageOptions{MaxWidth: -1}}, wantErr: true},
+		{name: "webp", job: Job{Format: "png", Image: &ImageOptions{Format: "webp", Quality: 80}}},
+		{name: "webp in PDF", job: Job{Format: "pdf", Image: &ImageOptions{Format: "webp"}}, wantErr: true},
+		{name: "unknown format", job: Job{Format: "png", Image: &ImageOptions{Format: "gif"}}, wantErr: true},
+		{name: "jpeg in PDF", job: Job{Format: "pdf", Image: &ImageOptions{Format: "jpeg"}}, wantErr: true},
+		{name: "quality out of range", job: Job{Format: "png", Image: &ImageOptions{Format: "jpeg", Quality: 101}}, wantErr: true},
+		{name: "empty watermark", job: Job{Format: "png", Image: &ImageOptions{Watermark: &Watermark{Position: "center"}}}, wantErr: true},
+		{name: "unknown position", job: Job{Format: "png", Image: &ImageOptions{Watermark: &Watermark{Text: "x", Position: "middle"}}}, wantErr: true},
+		{name: "opacity out of range", job: Job{Format: "png", Image: &ImageOptions{Watermark: &Watermark{Text: "x", Opacity: 2}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.job.validateImageOptions(); (err != nil) != tt.wantErr {
+				t.Errorf("validateImageOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// decodeTestPNG decodes a PNG produced by the image pipeline
+func decodeTestPNG(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected a PNG image: %v", err)
+	}
+	return img
+}
+
+// changedPixels counts the pixels of the region that are not white
+func changedPixels(img image.Image, region image.Rectangle) int {
+	changed := 0
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			if r, g, b, _ := img.At(x, y).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+				changed++
+			}
+		}
+	}
+	return changed
+}
+
+func TestProcessImage(t *testing.T) {
+	app := newTestApp(t)
+	rendered := testPNG(t, 800, 400, color.White)
+	at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	unchanged, err := app.processImage(Job{}, rendered, at)
+	if err != nil || !bytes.Equal(unchanged, rendered) {
+		t.Errorf("Expected images without options to be left as is, got %v", err)
+	}
+
+	job := Job{Image: &ImageOptions{Crop: &CropRegion{X: 100, Y: 50, Width: 600, Height: 300}, MaxWidth: 300}}
+	processed, err := app.processImage(job, rendered, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := decodeTestPNG(t, processed).Bounds().Size(); size != image.Pt(300, 150) {
+		t.Errorf("Expected the crop to be scaled down to 300x150, got %v", size)
+	}
+
+	job = Job{Image: &ImageOptions{Crop: &CropRegion{X: 900, Y: 0, Width: 100, Height: 100}}}
+	if _, err := app.processImage(job, rendered, at); err == nil {
+		t.Error("Expected a crop region outside the image to fail")
+	}
+
+	// The text is stamped in the bottom right corner by default
+	job = Job{Image: &ImageOptions{Watermark: &Watermark{Text: "CONFIDENTIAL - generated {date}", Opacity: 1}}}
+	processed, err = app.processImage(job, rendered, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := decodeTestPNG(t, processed)
+	if changedPixels(img, image.Rect(400, 300, 800, 400)) == 0 {
+		t.Error("Expected the watermark in the bottom right corner")
+	}
+	if changedPixels(img, image.Rect(0, 0, 400, 300)) != 0 {
+		t.Error("Expected the rest of the image to be left as is")
+	}
+
+	// Logos are read from the assets
+	os.MkdirAll(app.assetsDir, 0755)
+	os.WriteFile(filepath.Join(app.assetsDir, "stamp.png"), testPNG(t, 50, 50, color.Black), 0644)
+	job = Job{Image: &ImageOptions{Watermark: &Watermark{Logo: "stamp.png", Position: "topLeft", Opacity: 1}}}
+	processed, err = app.processImage(job, rendered, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = decodeTestPNG(t, processed)
+	if changedPixels(img, image.Rect(0, 0, 100, 100)) == 0 {
+		t.Error("Expected the logo in the top left corner")
+	}
+	if changedPixels(img, image.Rect(400, 200, 800, 400)) != 0 {
+		t.Error("Expected the rest of the image to be left as is")
+	}
+}
+
+func TestEncodeImages(t *testing.T) {
+	rendered := testPNG(t, 100, 50, color.White)
+	periods := []renderedPeriod{{label: "Current", data: rendered}, {label: "Previous", data: rendered}}
+
+	job := Job{Format: "html", Image: &ImageOptions{Format: "jpeg", Quality: 60}}
+	data, converted, err := job.encodeImages(rendered, periods)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, encoded := range [][]byte{data, converted[0].data, converted[1].data} {
+		if http.DetectContentType(encoded) != "image/jpeg" {
+			t.Errorf("Expected a JPEG image, got %s", http.DetectContentType(encoded))
+		}
+	}
+	if converted[1].label != "Previous" || periods[1].data == nil || !bytes.Equal(periods[1].data, rendered) {
+		t.Error("Expected the labels to be kept and the rendered periods to be left as is")
+	}
+
+	data, _, err = Job{Format: "png"}.encodeImages(rendered, nil)
+	if err != nil || !bytes.Equal(data, rendered) {
+		t.Errorf("Expected PNG images to be left as is, got %v", err)
+	}
+}
+
+func TestEncodeWebP(t *testing.T) {
+	// A noisy image, like anti-aliased text and charts, so that reducing the colours makes a difference
+	noise := rand.New(rand.NewSource(1))
+	noisy := image.NewNRGBA(image.Rect(0, 0, 256, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 256; x++ {
+			noisy.Set(x, y, color.NRGBA{R: uint8(200 + noise.Intn(40)), G: uint8(y * 4), B: uint8(255 - x), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, noisy); err != nil {
+		t.Fatal(err)
+	}
+	rendered := buf.Bytes()
+
+	lossless, _, err := Job{Format: "html", Image: &ImageOptions{Format: "webp"}}.encodeImages(rendered, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := webp.Decode(bytes.NewReader(lossless))
+	if err != nil {
+		t.Fatalf("Expected a WebP image: %v", err)
+	}
+	if got := color.NRGBAModel.Convert(img.At(200, 10)); got != noisy.At(200, 10) {
+		t.Errorf("Expected a lossless image by default, got %v at (200, 10)", got)
+	}
+
+	reduced, _, err := Job{Format: "html", Image: &ImageOptions{Format: "webp", Quality: 40}}.encodeImages(rendered, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := webp.Decode(bytes.NewReader(reduced)); err != nil {
+		t.Fatalf("Expected a WebP image: %v", err)
+	}
+	if len(reduced) >= len(lossless) {
+		t.Errorf("Expected a lower quality to give a smaller image, got %d bytes for %d lossless", len(reduced), len(lossless))
+	}
+}
+
+func TestExecuteJobSendsJPEG(t *testing.T) {
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testPNG(t, 1600, 900, color.White))
+	}))
+	defer grafana.Close()
+	smtpServer := startFakeSMTP(t)
+	port, _ := strconv.Atoi(smtpServer.port)
+
+	app := newTestApp(t)
+	app.config = Config{GrafanaURL: grafana.URL, SMTPHost: smtpServer.host, SMTPPort: port, SMTPFrom: "reports@example.com"}
+	job := Job{
+		ID:           "ops",
+		DashboardUID: "abc",
+		Format:       "png",
+		Recipients:   []string{"ops@example.com"},
+		Image:        &ImageOptions{MaxWidth: 800, Format: "jpeg", Watermark: &Watermark{Text: "CONFIDENTIAL"}},
+	}
+
+	if err := app.executeJob(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	messages := smtpServer.received()
+	if len(messages) != 1 {
+		t.Fatalf("Expected one email, got %d", len(messages))
+	}
+	if !strings.Contains(messages[0], "Content-Type: image/jpeg") || !strings.Contains(messages[0], ".jpg") {
+		t.Error("Expected a JPEG attachment")
+	}
+}
+
+func TestExecuteJobSendsWebP(t *testing.T) {
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testPNG(t, 400, 200, color.White))
+	}))
+	defer grafana.Close()
+	smtpServer := startFakeSMTP(t)
+	port, _ := strconv.Atoi(smtpServer.port)
+
+	app := newTestApp(t)
+	app.config = Config{GrafanaURL: grafana.URL, SMTPHost: smtpServer.host, SMTPPort: port, SMTPFrom: "reports@example.com"}
+	job := Job{ID: "ops", DashboardUID: "abc", Format: "png", Recipients: []string{"ops@example.com"}, Image: &ImageOptions{Format: "webp"}}
+
+	if err := app.executeJob(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	messages := smtpServer.received()
+	if len(messages) != 1 || !strings.Contains(messages[0], "Content-Type: image/webp") || !strings.Contains(messages[0], ".webp") {
+		t.Error("Expected a WebP attachment")
+	}
+}
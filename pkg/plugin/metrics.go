@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Execution outcomes used as the "outcome" label of metricExecutions
+const (
+	outcomeSuccess     = "success"
+	outcomeRenderError = "render_error"
+	outcomeEmailError  = "email_error"
+)
+
+// Metrics are registered with the default registry, which the plugin SDK exposes to Grafana
+// at /api/plugins/progressio-grafanareporter-app/metrics. They are also served by the /metrics resource.
+var (
+	metricJobsScheduled = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "grafanareporter",
+		Name:      "jobs_scheduled",
+		Help:      "Number of jobs currently registered with the scheduler.",
+	})
+
+	metricExecutions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafanareporter",
+		Name:      "executions_total",
+		Help:      "Number of job executions by outcome.",
+	}, []string{"outcome"})
+
+	metricExecutionsInProgress = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "grafanareporter",
+		Name:      "executions_in_progress",
+		Help:      "Number of job executions currently running.",
+	})
+
+	metricRenderDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "grafanareporter",
+		Name:      "render_duration_seconds",
+		Help:      "Time spent rendering reports, by format.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"format"})
+
+	metricRenderedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafanareporter",
+		Name:      "rendered_bytes_total",
+		Help:      "Total size of rendered reports, by format.",
+	}, []string{"format"})
+
+	metricEmailDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "grafanareporter",
+		Name:      "email_send_duration_seconds",
+		Help:      "Time spent delivering report emails.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	})
+
+	metricClaims = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafanareporter",
+		Name:      "occurrence_claims_total",
+		Help:      "Number of attempts to claim a job occurrence among replicas, by outcome.",
+	}, []string{"outcome"})
+
+	metricLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "grafanareporter",
+		Name:      "job_last_success_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful execution of each job.",
+	}, []string{"job_id"})
+)
@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestExecuteJobRecordsMetrics(t *testing.T) {
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "broken") {
+			http.Error(w, "renderer unavailable", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("fake png"))
+	}))
+	defer grafana.Close()
+
+	app := newTestApp(t)
+	app.config.GrafanaURL = grafana.URL
+
+	renderErrors := testutil.ToFloat64(metricExecutions.WithLabelValues(outcomeRenderError))
+	emailErrors := testutil.ToFloat64(metricExecutions.WithLabelValues(outcomeEmailError))
+	renderedBytes := testutil.ToFloat64(metricRenderedBytes.WithLabelValues("png"))
+
+	if err := app.executeJob(context.Background(), Job{ID: "broken", DashboardUID: "broken", Format: "png"}); err == nil {
+		t.Fatal("Expected render error")
+	}
+	if got := testutil.ToFloat64(metricExecutions.WithLabelValues(outcomeRenderError)); got != renderErrors+1 {
+		t.Errorf("Expected render errors to increase by 1, got %v -> %v", renderErrors, got)
+	}
+
+	// SMTP is not configured, so delivery fails after a successful render
+	if err := app.executeJob(context.Background(), Job{ID: "ok", DashboardUID: "ok", Format: "png"}); err == nil {
+		t.Fatal("Expected email error")
+	}
+	if got := testutil.ToFloat64(metricExecutions.WithLabelValues(outcomeEmailError)); got != emailErrors+1 {
+		t.Errorf("Expected email errors to increase by 1, got %v -> %v", emailErrors, got)
+	}
+	if got := testutil.ToFloat64(metricRenderedBytes.WithLabelValues("png")); got != renderedBytes+float64(len("fake png")) {
+		t.Errorf("Expected rendered bytes to increase by %d, got %v -> %v", len("fake png"), renderedBytes, got)
+	}
+	if got := testutil.ToFloat64(metricExecutionsInProgress); got != 0 {
+		t.Errorf("Expected no executions in progress, got %v", got)
+	}
+}
+
+func TestMetricsResource(t *testing.T) {
+	app := newTestApp(t)
+	if err := app.scheduleJob(Job{ID: "daily", Cron: "0 9 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := callResource(t, app, &backend.User{Login: "viewer", Role: roleViewer}, http.MethodGet, "/metrics", "")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.Status)
+	}
+	if !strings.Contains(string(resp.Body), "grafanareporter_jobs_scheduled 1") {
+		t.Errorf("Expected scheduled jobs gauge in metrics output")
+	}
+}
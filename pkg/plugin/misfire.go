@@ -0,0 +1,220 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/robfig/cron/v3"
+)
+
+// Misfire policies, applied on startup to the occurrences a job missed while the plugin was not running
+const (
+	misfireSkip = "skip" // do not run missed occurrences (default)
+	misfireOnce = "once" // run the job once, whatever the number of missed occurrences
+	misfireAll  = "all"  // run the job for each missed occurrence, up to misfireMaxRuns
+)
+
+// defaultMisfireMaxRuns caps the catch-up runs of the "all" policy when the job sets no limit
+const defaultMisfireMaxRuns = 10
+
+// triggerCatchUp is the trigger of runs started for missed occurrences
+const triggerCatchUp = "catchup"
+
+// validateMisfirePolicy checks the job's misfire settings
+func (job Job) validateMisfirePolicy() error {
+	switch job.MisfirePolicy {
+	case "", misfireSkip, misfireOnce, misfireAll:
+	default:
+		return fmt.Errorf("invalid misfire policy %q (expected %s, %s or %s)", job.MisfirePolicy, misfireSkip, misfireOnce, misfireAll)
+	}
+	if job.MisfireMaxRuns < 0 {
+		return fmt.Errorf("misfireMaxRuns must not be negative")
+	}
+	return nil
+}
+
+// misfirePolicy returns the job's misfire policy. One-shot jobs run once by default, since skipping
+// their only occurrence would mean never sending the report.
+func (job Job) misfirePolicy() string {
+	switch {
+	case job.MisfirePolicy != "":
+		return job.MisfirePolicy
+	case job.RunAt != nil:
+		return misfireOnce
+	default:
+		return misfireSkip
+	}
+}
+
+// loadLastFires loads the last scheduled fire time of each job from the schedule state file
+func (app *App) loadLastFires() error {
+	app.lastFiresMu.Lock()
+	defer app.lastFiresMu.Unlock()
+
+	app.lastFires = make(map[string]time.Time)
+
+	data, err := os.ReadFile(app.scheduleFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read schedule state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &app.lastFires); err != nil {
+		return fmt.Errorf("failed to parse schedule state file: %w", err)
+	}
+	return nil
+}
+
+// saveLastFiresLocked writes the last fire times to the schedule state file. The caller must hold lastFiresMu.
+func (app *App) saveLastFiresLocked() error {
+	data, err := json.MarshalIndent(app.lastFires, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedule state: %w", err)
+	}
+	if err := os.WriteFile(app.scheduleFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schedule state file: %w", err)
+	}
+	return nil
+}
+
+// recordFire persists the occurrence a job was fired for. With onlyIfUntracked, the occurrence is
+// only recorded for jobs that have not fired yet, as the point from which missed runs are counted.
+func (app *App) recordFire(jobID string, occurrence time.Time, onlyIfUntracked bool) {
+	app.lastFiresMu.Lock()
+	defer app.lastFiresMu.Unlock()
+
+	if app.lastFires == nil {
+		app.lastFires = make(map[string]time.Time)
+	}
+	if _, tracked := app.lastFires[jobID]; tracked && onlyIfUntracked {
+		return
+	}
+	app.lastFires[jobID] = occurrence
+	if err := app.saveLastFiresLocked(); err != nil {
+		log.DefaultLogger.Error("Failed to save schedule state", "error", err)
+	}
+}
+
+// missedRuns describes the occurrences a job missed
+type missedRuns struct {
+	first  []time.Time // the first occurrences, up to the requested limit
+	latest time.Time   // the most recent occurrence
+	total  int
+}
+
+// missedOccurrences returns the occurrences of the schedule after last and up to now
+func missedOccurrences(schedule cron.Schedule, last, now time.Time, limit int) missedRuns {
+	var missed missedRuns
+	for next := schedule.Next(last); !next.IsZero() && !next.After(now); next = schedule.Next(next) {
+		if len(missed.first) < limit {
+			missed.first = append(missed.first, next)
+		}
+		missed.latest = next
+		missed.total++
+	}
+	return missed
+}
+
+// catchUpMissedRuns applies each job's misfire policy to the occurrences it missed since its last
+// recorded fire. Jobs without a recorded fire start being tracked from now.
+func (app *App) catchUpMissedRuns(now time.Time) {
+	app.mu.RLock()
+	jobs := make([]Job, 0, len(app.jobs))
+	for _, job := range app.jobs {
+		jobs = append(jobs, job)
+	}
+	app.mu.RUnlock()
+
+	app.lastFiresMu.Lock()
+	if app.lastFires == nil {
+		app.lastFires = make(map[string]time.Time)
+	}
+
+	// Forget deleted jobs
+	for jobID := range app.lastFires {
+		found := false
+		for _, job := range jobs {
+			found = found || job.ID == jobID
+		}
+		if !found {
+			delete(app.lastFires, jobID)
+		}
+	}
+
+	catchUp := make(map[string][]time.Time)
+	for _, job := range jobs {
+		last, ok := app.lastFires[job.ID]
+		if !ok {
+			app.lastFires[job.ID] = now
+			continue
+		}
+		if job.Disabled {
+			continue
+		}
+
+		maxRuns := job.MisfireMaxRuns
+		if maxRuns == 0 {
+			maxRuns = defaultMisfireMaxRuns
+		}
+
+		schedule, err := app.jobSchedule(job)
+		if err != nil {
+			log.DefaultLogger.Error("Failed to compute missed runs", "id", job.ID, "error", err)
+			continue
+		}
+		missed := missedOccurrences(schedule, last, now, maxRuns)
+		if missed.total == 0 {
+			continue
+		}
+
+		// Missed occurrences are handled once, whatever the policy
+		app.lastFires[job.ID] = missed.latest
+
+		switch job.misfirePolicy() {
+		case misfireOnce:
+			log.DefaultLogger.Warn("Job missed runs, running it once", "id", job.ID, "since", last, "missed", missed.total)
+			catchUp[job.ID] = []time.Time{missed.latest}
+		case misfireAll:
+			log.DefaultLogger.Warn("Job missed runs, running each of them", "id", job.ID, "since", last, "missed", missed.total, "runs", len(missed.first))
+			catchUp[job.ID] = missed.first
+		default:
+			log.DefaultLogger.Warn("Job missed runs, skipping them", "id", job.ID, "since", last, "missed", missed.total)
+		}
+	}
+
+	if err := app.saveLastFiresLocked(); err != nil {
+		log.DefaultLogger.Error("Failed to save schedule state", "error", err)
+	}
+	app.lastFiresMu.Unlock()
+
+	for _, job := range jobs {
+		if occurrences, ok := catchUp[job.ID]; ok {
+			go func(job Job, occurrences []time.Time) {
+				// Runs of the same job are started one after the other
+				for _, occurrence := range occurrences {
+					app.runOccurrence(job, occurrence, triggerCatchUp)
+				}
+			}(job, occurrences)
+		}
+	}
+}
+
+// runOccurrence executes a job for one occurrence of its schedule and waits for it to finish.
+// It reports false without running the job when another replica claimed the occurrence.
+func (app *App) runOccurrence(job Job, occurrence time.Time, trigger string) (Run, bool) {
+	if !app.claimOccurrence(job.ID, occurrence) {
+		return Run{}, false
+	}
+
+	active := app.startRun(context.Background(), job, trigger)
+	app.updateRun(active, func(run *Run) {
+		scheduledFor := occurrence.UTC()
+		run.ScheduledFor = &scheduledFor
+	})
+	return app.executeRun(active, job), true
+}
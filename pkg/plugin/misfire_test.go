@@ -0,0 +1,120 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestMissedOccurrences(t *testing.T) {
+	last := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	now := time.Date(2024, 1, 4, 10, 0, 0, 0, time.UTC)
+
+	schedule, _ := cron.ParseStandard("0 9 * * *")
+	missed := missedOccurrences(schedule, last, now, 2)
+	if missed.total != 3 {
+		t.Errorf("Expected 3 missed occurrences, got %d", missed.total)
+	}
+	if len(missed.first) != 2 || !missed.first[0].Equal(time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the first 2 occurrences from Jan 2, got %v", missed.first)
+	}
+	if !missed.latest.Equal(time.Date(2024, 1, 4, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected latest occurrence on Jan 4, got %v", missed.latest)
+	}
+
+	missed = missedOccurrences(schedule, now, now.Add(time.Hour), 10)
+	if missed.total != 0 {
+		t.Errorf("Expected no missed occurrence, got %d", missed.total)
+	}
+}
+
+func TestValidateMisfirePolicy(t *testing.T) {
+	tests := []struct {
+		job     Job
+		wantErr bool
+	}{
+		{Job{}, false},
+		{Job{MisfirePolicy: misfireSkip}, false},
+		{Job{MisfirePolicy: misfireOnce}, false},
+		{Job{MisfirePolicy: misfireAll, MisfireMaxRuns: 3}, false},
+		{Job{MisfirePolicy: "sometimes"}, true},
+		{Job{MisfirePolicy: misfireAll, MisfireMaxRuns: -1}, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.job.validateMisfirePolicy(); (err != nil) != tt.wantErr {
+			t.Errorf("validateMisfirePolicy(%+v) error = %v, wantErr %v", tt.job, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCatchUpMissedRuns(t *testing.T) {
+	var mu sync.Mutex
+	renders := map[string]int{}
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		renders[strings.Split(strings.TrimPrefix(r.URL.Path, "/render/d/"), "/")[0]]++
+		mu.Unlock()
+		w.Write([]byte("png"))
+	}))
+	defer grafana.Close()
+
+	app := newTestApp(t)
+	app.config.GrafanaURL = grafana.URL
+
+	now := time.Now()
+	threeDaysAgo := now.Add(-72 * time.Hour)
+	for _, job := range []Job{
+		{ID: "skip", DashboardUID: "skip", Cron: "0 9 * * *"},
+		{ID: "once", DashboardUID: "once", Cron: "0 9 * * *", MisfirePolicy: misfireOnce},
+		{ID: "all", DashboardUID: "all", Cron: "0 9 * * *", MisfirePolicy: misfireAll, MisfireMaxRuns: 2},
+		{ID: "new", DashboardUID: "new", Cron: "0 9 * * *", MisfirePolicy: misfireAll},
+	} {
+		job.Format = "png"
+		app.jobs[job.ID] = job
+	}
+	app.lastFires = map[string]time.Time{
+		"skip":    threeDaysAgo,
+		"once":    threeDaysAgo,
+		"all":     threeDaysAgo,
+		"deleted": threeDaysAgo,
+	}
+
+	app.catchUpMissedRuns(now)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(app.recentRuns()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for catch-up runs, got %+v", app.recentRuns())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitForNoActiveRuns(t, app)
+
+	mu.Lock()
+	if renders["skip"] != 0 || renders["once"] != 1 || renders["all"] != 2 || renders["new"] != 0 {
+		t.Errorf("Unexpected catch-up renders: %v", renders)
+	}
+	mu.Unlock()
+
+	for _, run := range app.recentRuns() {
+		if run.Trigger != triggerCatchUp || run.ScheduledFor == nil || run.ScheduledFor.Before(threeDaysAgo) {
+			t.Errorf("Expected a catch-up run for a missed occurrence, got %+v", run)
+		}
+	}
+
+	if !app.lastFires["skip"].After(threeDaysAgo) {
+		t.Error("Expected skipped occurrences to be marked as handled")
+	}
+	if !app.lastFires["new"].Equal(now) {
+		t.Errorf("Expected new job to be tracked from now, got %v", app.lastFires["new"])
+	}
+	if _, ok := app.lastFires["deleted"]; ok {
+		t.Error("Expected deleted job to be forgotten")
+	}
+}
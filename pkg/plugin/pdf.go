@@ -0,0 +1,379 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Paper sizes and orientations of PDF reports
+const (
+	paperA4        = "a4"
+	paperLetter    = "letter"
+	pagePortrait   = "portrait"
+	pageLandscape  = "landscape"
+	pdfMargin      = 10.0 // mm
+	pdfHeaderSpace = 16.0 // mm reserved below the top margin for the header
+	pdfFooterSpace = 10.0 // mm reserved above the bottom margin for the footer
+	pdfHeadingSize = 10.0 // mm taken by the title of a section on its first page
+	pdfTOCEntry    = 8.0  // mm per table of contents entry
+	pdfLogoWidth   = 70.0 // mm, largest logo width on the cover page
+	pdfLogoHeight  = 30.0 // mm, largest logo height on the cover page
+)
+
+// pdfSection is a part of a PDF report, listed in the table of contents
+type pdfSection struct {
+	title string
+	image []byte // rendered PNG
+}
+
+// PDFOptions controls the page layout of PDF reports
+type PDFOptions struct {
+	PaperSize   string `json:"paperSize,omitempty"`   // a4 (default) or letter
+	Orientation string `json:"orientation,omitempty"` // portrait (default) or landscape
+	Title       string `json:"title,omitempty"`       // Shown in the page header, default: the email subject
+
+	CoverPage             bool   `json:"coverPage,omitempty"`             // Start with a cover page showing the logo, title, period and notice
+	TableOfContents       bool   `json:"tableOfContents,omitempty"`       // List the report sections, with links, after the cover page
+	Logo                  string `json:"logo,omitempty"`                  // Name of the uploaded asset shown on the cover page
+	ConfidentialityNotice string `json:"confidentialityNotice,omitempty"` // Shown at the bottom of the cover page
+
+	Encryption *PDFEncryption `json:"encryption,omitempty"` // Password protection of the document
+}
+
+// pdfOptions returns the job's PDF options, or the defaults
+func (job Job) pdfOptions() PDFOptions {
+	if job.PDF == nil {
+		return PDFOptions{}
+	}
+	return *job.PDF
+}
+
+// validatePDFOptions checks the job's PDF options
+func (job Job) validatePDFOptions() error {
+	options := job.pdfOptions()
+	switch options.PaperSize {
+	case "", paperA4, paperLetter:
+	default:
+		return fmt.Errorf("invalid paper size %q (expected %s or %s)", options.PaperSize, paperA4, paperLetter)
+	}
+	switch options.Orientation {
+	case "", pagePortrait, pageLandscape:
+	default:
+		return fmt.Errorf("invalid orientation %q (expected %s or %s)", options.Orientation, pagePortrait, pageLandscape)
+	}
+	return job.validatePDFEncryption()
+}
+
+// pdfTitle returns the title shown in the header of the job's PDF pages
+func (job Job) pdfTitle() string {
+	switch {
+	case job.pdfOptions().Title != "":
+		return job.pdfOptions().Title
+	case job.Subject != "":
+		return job.Subject
+	default:
+		return job.ID
+	}
+}
+
+// sectionTitle names the dashboard or panel of the job in the table of contents
+func (job Job) sectionTitle() string {
+	name := job.Slug
+	if name == "" {
+		name = job.DashboardUID
+	}
+	if job.PanelID != nil {
+		return fmt.Sprintf("Panel %d of %s", *job.PanelID, name)
+	}
+	return "Dashboard " + name
+}
+
+// pdfPeriod describes the resolved time range of the job for the page header
+func (job Job) pdfPeriod() string {
+	if label := periodLabel("Period", job); label != "Period" {
+		return label
+	}
+	if job.From == "" && job.To == "" {
+		return ""
+	}
+	return fmt.Sprintf("Period: %s to %s", job.From, job.To)
+}
+
+// composeReportPDF composes the PDF of a job's rendered report, with one section for the dashboard
+// or panel, or one per period for comparisons. A logo that cannot be read is left out. When the job
+// encrypts its reports, the document is protected with password.
+func (app *App) composeReportPDF(job Job, rendered []byte, periods []renderedPeriod, password string) ([]byte, error) {
+	sections := []pdfSection{{title: job.sectionTitle(), image: rendered}}
+	if len(periods) > 0 {
+		sections = make([]pdfSection, len(periods))
+		for i, period := range periods {
+			sections[i] = pdfSection{title: period.label, image: period.data}
+		}
+	}
+
+	var logo []byte
+	if name := job.pdfOptions().Logo; name != "" {
+		var err error
+		if logo, err = app.readAsset(name); err != nil {
+			log.DefaultLogger.Warn("Failed to read PDF logo, leaving it out", "id", job.ID, "logo", name, "error", err)
+		} else if _, _, err = image.Decode(bytes.NewReader(logo)); err != nil {
+			// A corrupt logo would otherwise fail the whole document
+			log.DefaultLogger.Warn("Failed to decode PDF logo, leaving it out", "id", job.ID, "logo", name, "error", err)
+			logo = nil
+		}
+	}
+	return composePDF(job, sections, logo, password, time.Now())
+}
+
+// sectionLayout is a section placed on pages
+type sectionLayout struct {
+	pdfSection
+	img        image.Image
+	mmPerPixel float64
+	firstPage  int
+	link       int
+}
+
+// rows returns the number of image rows shown on the given page of the section; the first page
+// also holds the section title when sections have headings
+func (l sectionLayout) rows(part int, contentHeight float64, headings bool) int {
+	if part == 0 && headings {
+		contentHeight -= pdfHeadingSize
+	}
+	return int(contentHeight / l.mmPerPixel)
+}
+
+// composePDF lays the sections out on printable pages, after an optional cover page and table of
+// contents. The image of each section is scaled to the page width and split across as many pages
+// as needed, below a header with the report title and period and above a footer with the page
+// number and generation time. Sections have a title when there are several or a table of contents.
+// Documents of jobs that encrypt their reports are then encrypted, to be opened with password.
+func composePDF(job Job, sections []pdfSection, logo []byte, password string, generatedAt time.Time) ([]byte, error) {
+	options := job.pdfOptions()
+	orientation, size := "P", "A4"
+	if options.Orientation == pageLandscape {
+		orientation = "L"
+	}
+	if options.PaperSize == paperLetter {
+		size = "Letter"
+	}
+
+	pdf := gofpdf.New(orientation, "mm", size, "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AliasNbPages("")
+	pdf.SetTitle(job.pdfTitle(), true)
+	pdf.SetCreator("Grafana Reporter", true)
+
+	// Core fonts only cover cp1252, which is enough for the titles of most reports
+	translate := pdf.UnicodeTranslatorFromDescriptor("")
+	loc, err := job.location()
+	if err != nil {
+		loc = time.UTC
+	}
+	title, period := translate(job.pdfTitle()), translate(job.pdfPeriod())
+	generated := translate("Generated " + generatedAt.In(loc).Format("2006-01-02 15:04 MST"))
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	contentWidth := pageWidth - 2*pdfMargin
+	contentTop := pdfMargin + pdfHeaderSpace
+	contentHeight := pageHeight - contentTop - pdfMargin - pdfFooterSpace
+	headings := len(sections) > 1 || options.TableOfContents
+
+	// Place the sections first, so that the table of contents knows their pages
+	page := 1
+	if options.CoverPage {
+		page++
+	}
+	tocEntriesPerPage := int((contentHeight - pdfHeadingSize) / pdfTOCEntry)
+	if options.TableOfContents {
+		page += (len(sections) + tocEntriesPerPage - 1) / tocEntriesPerPage
+	}
+	layouts := make([]sectionLayout, len(sections))
+	for i, section := range sections {
+		img, err := png.Decode(bytes.NewReader(section.image))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode rendered image: %w", err)
+		}
+
+		// Scale the image to the page width, without enlarging small panels beyond 1 px = 0.25 mm
+		mmPerPixel := contentWidth / float64(img.Bounds().Dx())
+		if mmPerPixel > 0.25 {
+			mmPerPixel = 0.25
+		}
+		layouts[i] = sectionLayout{pdfSection: section, img: img, mmPerPixel: mmPerPixel, firstPage: page, link: pdf.AddLink()}
+
+		for part, remaining := 0, img.Bounds().Dy(); remaining > 0; part++ {
+			remaining -= layouts[i].rows(part, contentHeight, headings)
+			page++
+		}
+	}
+
+	pdf.SetHeaderFunc(func() {
+		if options.CoverPage && pdf.PageNo() == 1 {
+			return
+		}
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(contentWidth, 6, title, "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.SetTextColor(96, 96, 96)
+		pdf.CellFormat(contentWidth, 5, period, "", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Line(pdfMargin, contentTop-3, pageWidth-pdfMargin, contentTop-3)
+	})
+	pdf.SetFooterFunc(func() {
+		if options.CoverPage && pdf.PageNo() == 1 {
+			return
+		}
+		pdf.SetY(pageHeight - pdfMargin - 5)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(96, 96, 96)
+		pdf.CellFormat(contentWidth/2, 5, generated, "", 0, "L", false, 0, "")
+		pdf.CellFormat(contentWidth/2, 5, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	if options.CoverPage {
+		pdf.AddPage()
+		drawCover(pdf, logo, title, period, generated, translate(options.ConfidentialityNotice))
+	}
+
+	if options.TableOfContents {
+		for i, layout := range layouts {
+			if i%tocEntriesPerPage == 0 {
+				pdf.AddPage()
+				pdf.SetXY(pdfMargin, contentTop)
+				pdf.SetFont("Helvetica", "B", 14)
+				pdf.CellFormat(contentWidth, pdfHeadingSize, "Contents", "", 1, "L", false, 0, "")
+				pdf.SetFont("Helvetica", "", 11)
+			}
+			pdf.CellFormat(contentWidth-20, pdfTOCEntry, translate(layout.title), "", 0, "L", false, layout.link, "")
+			pdf.CellFormat(20, pdfTOCEntry, fmt.Sprint(layout.firstPage), "", 1, "R", false, layout.link, "")
+		}
+	}
+
+	for i, layout := range layouts {
+		bounds := layout.img.Bounds()
+		top := bounds.Min.Y
+		for part := 0; top < bounds.Max.Y; part++ {
+			pdf.AddPage()
+			y := contentTop
+			if part == 0 {
+				pdf.SetLink(layout.link, 0, -1)
+				pdf.Bookmark(translate(layout.title), 0, -1)
+				if headings {
+					pdf.SetXY(pdfMargin, contentTop)
+					pdf.SetFont("Helvetica", "B", 11)
+					pdf.CellFormat(contentWidth, pdfHeadingSize-2, translate(layout.title), "", 1, "L", false, 0, "")
+					y += pdfHeadingSize
+				}
+			}
+
+			bottom := top + layout.rows(part, contentHeight, headings)
+			if bottom > bounds.Max.Y {
+				bottom = bounds.Max.Y
+			}
+			strip, err := encodeStrip(layout.img, image.Rect(bounds.Min.X, top, bounds.Max.X, bottom))
+			if err != nil {
+				return nil, err
+			}
+
+			name := fmt.Sprintf("section-%d-%d", i, part)
+			imageOptions := gofpdf.ImageOptions{ImageType: "PNG"}
+			pdf.RegisterImageOptionsReader(name, imageOptions, bytes.NewReader(strip))
+			pdf.ImageOptions(name, pdfMargin, y, float64(bounds.Dx())*layout.mmPerPixel, float64(bottom-top)*layout.mmPerPixel, false, imageOptions, 0, "")
+			top = bottom
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	if encryption := job.pdfEncryption(); encryption != nil {
+		return encryption.encrypt(buf.Bytes(), password)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawCover draws the cover page: the logo, the title, the period and generation time, and the
+// confidentiality notice at the bottom
+func drawCover(pdf *gofpdf.Fpdf, logo []byte, title, period, generated, notice string) {
+	pageWidth, pageHeight := pdf.GetPageSize()
+	contentWidth := pageWidth - 2*pdfMargin
+	y := pageHeight / 6
+
+	if imageType := logoImageType(logo); imageType != "" {
+		imageOptions := gofpdf.ImageOptions{ImageType: imageType}
+		info := pdf.RegisterImageOptionsReader("logo", imageOptions, bytes.NewReader(logo))
+		if pdf.Ok() {
+			width, height := info.Extent()
+			scale := pdfLogoWidth / width
+			if s := pdfLogoHeight / height; s < scale {
+				scale = s
+			}
+			width, height = width*scale, height*scale
+			pdf.ImageOptions("logo", (pageWidth-width)/2, y, width, height, false, imageOptions, 0, "")
+		} else {
+			// The cover is drawn without a logo that cannot be read
+			pdf.ClearError()
+		}
+	}
+
+	pdf.SetXY(pdfMargin, y+pdfLogoHeight+25)
+	pdf.SetFont("Helvetica", "B", 24)
+	pdf.MultiCell(contentWidth, 11, title, "", "C", false)
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "", 13)
+	pdf.SetTextColor(64, 64, 64)
+	pdf.MultiCell(contentWidth, 7, period, "", "C", false)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.MultiCell(contentWidth, 6, generated, "", "C", false)
+	pdf.SetTextColor(0, 0, 0)
+
+	if notice != "" {
+		pdf.SetXY(pdfMargin, pageHeight-pdfMargin-40)
+		pdf.SetFont("Helvetica", "I", 9)
+		pdf.SetDrawColor(160, 160, 160)
+		pdf.MultiCell(contentWidth, 5, notice, "1", "C", false)
+		pdf.SetDrawColor(0, 0, 0)
+	}
+}
+
+// logoImageType returns the gofpdf image type of a logo, or "" when it is missing or not supported
+func logoImageType(logo []byte) string {
+	if len(logo) == 0 {
+		return ""
+	}
+	switch http.DetectContentType(logo) {
+	case "image/png":
+		return "PNG"
+	case "image/jpeg":
+		return "JPG"
+	default:
+		return ""
+	}
+}
+
+// encodeStrip encodes the part of the image within rect as PNG
+func encodeStrip(img image.Image, rect image.Rectangle) ([]byte, error) {
+	sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, fmt.Errorf("unsupported image type %T", img)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sub.SubImage(rect)); err != nil {
+		return nil, fmt.Errorf("failed to encode page image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
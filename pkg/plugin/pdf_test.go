@@ -0,0 +1,157 @@
+package plugin
+
+import (
+	"bytes"
+	"image/color"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// pdfPageCount returns the page count declared by the page tree of a PDF
+func pdfPageCount(t *testing.T, pdf []byte) int {
+	t.Helper()
+	match := regexp.MustCompile(`/Type /Pages\s*/Kids \[[^\]]*\]\s*/Count (\d+)`).FindSubmatch(pdf)
+	if match == nil {
+		t.Fatal("No page tree in PDF")
+	}
+	count, _ := strconv.Atoi(string(match[1]))
+	return count
+}
+
+func TestComposePDF(t *testing.T) {
+	generatedAt := time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC)
+	tall := testPNG(t, 800, 5000, color.White)
+
+	tests := []struct {
+		name  string
+		job   Job
+		image []byte
+		pages int
+	}{
+		// 800 px across 190 mm leave 1056 rows on each of the 251 mm high A4 pages
+		{name: "tall dashboard on A4", job: Job{ID: "ops", Subject: "Weekly"}, image: tall, pages: 5},
+		// Images are not enlarged beyond 0.25 mm per pixel, leaving 679 rows on each 169.9 mm high page
+		{name: "tall dashboard on landscape letter", job: Job{ID: "ops", PDF: &PDFOptions{PaperSize: paperLetter, Orientation: pageLandscape}}, image: tall, pages: 8},
+		{name: "small panel", job: Job{ID: "ops", From: "1700000000000", To: "1700003600000"}, image: testPNG(t, 400, 300, color.White), pages: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf, err := composePDF(tt.job, []pdfSection{{title: "Dashboard ops", image: tt.image}}, nil, "", generatedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+				t.Fatalf("Expected a PDF document, got %q", pdf[:10])
+			}
+			if got := pdfPageCount(t, pdf); got != tt.pages {
+				t.Errorf("Expected %d pages, got %d", tt.pages, got)
+			}
+		})
+	}
+
+	if _, err := composePDF(Job{}, []pdfSection{{image: []byte("%PDF-1.4 from the renderer")}}, nil, "", generatedAt); err == nil {
+		t.Error("Expected error for a render that is not a PNG")
+	}
+}
+
+func TestComposePDFCoverAndContents(t *testing.T) {
+	tall := testPNG(t, 800, 5000, color.White)
+	sections := []pdfSection{{title: "Current period", image: tall}, {title: "Previous period", image: tall}}
+	job := Job{ID: "board", Subject: "Board pack", PDF: &PDFOptions{
+		CoverPage:             true,
+		TableOfContents:       true,
+		ConfidentialityNotice: "Confidential – board members only",
+	}}
+
+	pdf, err := composePDF(job, sections, testPNG(t, 300, 100, color.Black), "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cover and contents, then 5 pages per section: the section title leaves 1014 rows on its
+	// first page, and the remaining 3986 rows need 4 more pages
+	if got := pdfPageCount(t, pdf); got != 12 {
+		t.Errorf("Expected 12 pages, got %d", got)
+	}
+	// Each contents entry links its title and its page number to the section
+	if got := bytes.Count(pdf, []byte("/Subtype /Link")); got != 4 {
+		t.Errorf("Expected 4 links, got %d", got)
+	}
+	if !bytes.Contains(pdf, []byte("/Outlines")) {
+		t.Error("Expected the sections to be bookmarked")
+	}
+
+	// Without a usable logo, the cover is drawn without it
+	logo := testPNG(t, 300, 100, color.Black)
+	for _, unusable := range [][]byte{[]byte("not an image"), logo[:20]} {
+		if _, err := composePDF(job, sections, unusable, "", time.Now()); err != nil {
+			t.Errorf("Expected an unusable logo to be left out, got %v", err)
+		}
+	}
+}
+
+func TestComposeReportPDFSections(t *testing.T) {
+	app := newTestApp(t)
+	panelID := 2
+	job := Job{ID: "ops", Slug: "ops", PanelID: &panelID, Format: "pdf", PDF: &PDFOptions{Logo: "missing.png", CoverPage: true}}
+	if got := job.sectionTitle(); got != "Panel 2 of ops" {
+		t.Errorf("sectionTitle() = %q", got)
+	}
+
+	image := testPNG(t, 400, 300, color.White)
+	pdf, err := app.composeReportPDF(job, image, []renderedPeriod{{label: "Current", data: image}, {label: "Previous", data: image}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cover, then one page per period
+	if got := pdfPageCount(t, pdf); got != 3 {
+		t.Errorf("Expected 3 pages, got %d", got)
+	}
+
+	// A truncated logo asset is left out too
+	logo := testPNG(t, 300, 100, color.Black)
+	if err := os.MkdirAll(app.assetsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(app.assetsDir, "truncated.png"), logo[:20], 0644); err != nil {
+		t.Fatal(err)
+	}
+	job.PDF.Logo = "truncated.png"
+	if _, err := app.composeReportPDF(job, image, nil, ""); err != nil {
+		t.Errorf("Expected a truncated logo to be left out, got %v", err)
+	}
+}
+
+func TestPDFHeader(t *testing.T) {
+	job := Job{ID: "ops", Subject: "Weekly report", Timezone: "UTC", From: "1743379200000", To: "1743465599999"}
+	if got := job.pdfTitle(); got != "Weekly report" {
+		t.Errorf("Expected the subject as title, got %q", got)
+	}
+	job.PDF = &PDFOptions{Title: "Operations"}
+	if got := job.pdfTitle(); got != "Operations" {
+		t.Errorf("Expected the configured title, got %q", got)
+	}
+	if got, want := job.pdfPeriod(), "Period: 2025-03-31 00:00 to 2025-03-31 23:59 (UTC)"; got != want {
+		t.Errorf("pdfPeriod() = %q, want %q", got, want)
+	}
+	if got, want := (Job{From: "2025-01-01", To: "2025-02-01"}).pdfPeriod(), "Period: 2025-01-01 to 2025-02-01"; got != want {
+		t.Errorf("pdfPeriod() = %q, want %q", got, want)
+	}
+}
+
+func TestValidatePDFOptions(t *testing.T) {
+	for _, options := range []*PDFOptions{nil, {PaperSize: paperA4, Orientation: pagePortrait}, {PaperSize: paperLetter, Orientation: pageLandscape}} {
+		if err := (Job{PDF: options}).validatePDFOptions(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", options, err)
+		}
+	}
+	for _, options := range []*PDFOptions{{PaperSize: "A3"}, {Orientation: "sideways"}} {
+		if err := (Job{PDF: options}).validatePDFOptions(); err == nil {
+			t.Errorf("Expected %+v to be invalid", options)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// recipientPasswordLength is the length of the passwords derived for each recipient
+const recipientPasswordLength = 12
+
+func init() {
+	// pdfcpu otherwise reads its defaults from a configuration directory it creates, and exits the
+	// process when it cannot
+	model.ConfigPath = "disable"
+}
+
+// PDFEncryption protects PDF reports with a password. Documents are encrypted with AES-256 (PDF 2.0
+// standard security handler, revision 6); the permissions are advisory.
+type PDFEncryption struct {
+	Password      string `json:"password"`                // Needed to open the document; in perRecipient mode, the secret each recipient's password is derived from
+	OwnerPassword string `json:"ownerPassword,omitempty"` // Opens the document without restrictions; random when empty, so nobody has full access
+	PerRecipient  bool   `json:"perRecipient,omitempty"`  // Send each recipient a document with their own password
+	NoPrint       bool   `json:"noPrint,omitempty"`
+	NoCopy        bool   `json:"noCopy,omitempty"`
+	NoModify      bool   `json:"noModify,omitempty"`
+	NoAnnotate    bool   `json:"noAnnotate,omitempty"`
+}
+
+// RecipientPassword is the password of the PDF reports sent to a recipient
+type RecipientPassword struct {
+	Recipient string `json:"recipient"`
+	Password  string `json:"password"`
+}
+
+// delivery is a report attachment and the recipients it is sent to
+type delivery struct {
+	recipients []string
+	attachment []byte
+}
+
+// pdfEncryption returns the job's PDF encryption settings, or nil if its reports are not encrypted
+func (job Job) pdfEncryption() *PDFEncryption {
+	if job.PDF == nil {
+		return nil
+	}
+	return job.PDF.Encryption
+}
+
+// validatePDFEncryption checks the job's PDF encryption settings
+func (job Job) validatePDFEncryption() error {
+	encryption := job.pdfEncryption()
+	if encryption == nil {
+		return nil
+	}
+	if job.Format != "pdf" {
+		return fmt.Errorf("PDF encryption requires the pdf format, got %q", job.Format)
+	}
+	if encryption.Password == "" || encryption.Password == secretPlaceholder {
+		return fmt.Errorf("PDF encryption requires a password")
+	}
+	if encryption.OwnerPassword == secretPlaceholder {
+		return fmt.Errorf("invalid PDF owner password")
+	}
+	return nil
+}
+
+// permissions returns the operations allowed to readers who opened the document with their password
+func (e PDFEncryption) permissions() model.PermissionFlags {
+	allowed := model.PermissionsNone
+	if !e.NoPrint {
+		allowed |= model.PermissionPrintRev2 | model.PermissionPrintRev3
+	}
+	if !e.NoModify {
+		allowed |= model.PermissionModify | model.PermissionAssembleRev3
+	}
+	if !e.NoCopy {
+		allowed |= model.PermissionExtract | model.PermissionExtractRev3
+	}
+	if !e.NoAnnotate {
+		allowed |= model.PermissionModAnnFillForm | model.PermissionFillRev3
+	}
+	return allowed
+}
+
+// encrypt protects a PDF document with AES-256, to be opened with password. Without an owner
+// password, a random one is used so that nobody has unrestricted access.
+func (e PDFEncryption) encrypt(document []byte, password string) ([]byte, error) {
+	owner := e.OwnerPassword
+	if owner == "" {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("failed to generate owner password: %w", err)
+		}
+		owner = hex.EncodeToString(random)
+	}
+
+	conf := model.NewAESConfiguration(password, owner, 256)
+	conf.Permissions = e.permissions()
+	conf.Cmd = model.ENCRYPT
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(document), conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+
+	// PDF 2.0 documents get revision 6 of the security handler, which fixes the weak password
+	// hashing of revision 5
+	version := model.V20
+	ctx.HeaderVersion = &version
+	ctx.RootVersion = nil
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to encrypt PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// recipientPassword derives the password of a recipient from the job's password, so that it stays
+// the same from one report to the next without being stored
+func (e PDFEncryption) recipientPassword(recipient string) string {
+	mac := hmac.New(sha256.New, []byte(e.Password))
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(recipient))))
+	encoded := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(mac.Sum(nil))
+	return strings.ToLower(encoded[:recipientPasswordLength])
+}
+
+// pdfDeliveries composes the PDF of the job's report for its recipients: a single document, or
+// one per recipient when passwords are derived per recipient
+func (app *App) pdfDeliveries(job Job, recipients []string, rendered []byte, periods []renderedPeriod) ([]delivery, error) {
+	encryption := job.pdfEncryption()
+	if encryption == nil || !encryption.PerRecipient {
+		password := ""
+		if encryption != nil {
+			password = encryption.Password
+		}
+		document, err := app.composeReportPDF(job, rendered, periods, password)
+		if err != nil {
+			return nil, err
+		}
+		return []delivery{{recipients: recipients, attachment: document}}, nil
+	}
+
+	deliveries := make([]delivery, 0, len(recipients))
+	for _, recipient := range recipients {
+		document, err := app.composeReportPDF(job, rendered, periods, encryption.recipientPassword(recipient))
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery{recipients: []string{recipient}, attachment: document})
+	}
+	return deliveries, nil
+}
+
+// handlePDFPasswords lists the password of each recipient of a job whose PDF reports are encrypted,
+// so that they can be handed out separately from the reports
+func (app *App) handlePDFPasswords(w http.ResponseWriter, r *http.Request, jobID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	app.mu.RLock()
+	job, ok := app.jobs[jobID]
+	app.mu.RUnlock()
+
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if !canManageJob(requestUser(r), job) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	encryption := job.pdfEncryption()
+	if encryption == nil {
+		http.Error(w, "Job does not encrypt its reports", http.StatusNotFound)
+		return
+	}
+
+	recipients := job.deliveryRecipients()
+	passwords := make([]RecipientPassword, 0, len(recipients))
+	for _, recipient := range recipients {
+		password := encryption.Password
+		if encryption.PerRecipient {
+			password = encryption.recipientPassword(recipient)
+		}
+		passwords = append(passwords, RecipientPassword{Recipient: recipient, Password: password})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(passwords)
+}
+
+// withEncryption returns a copy of the job with its own copy of the PDF encryption settings, changed by update
+func (job Job) withEncryption(update func(*PDFEncryption) error) (Job, error) {
+	if job.pdfEncryption() == nil {
+		return job, nil
+	}
+	options := *job.PDF
+	encryption := *options.Encryption
+	if err := update(&encryption); err != nil {
+		return job, err
+	}
+	options.Encryption = &encryption
+	job.PDF = &options
+	return job, nil
+}
+
+// redacted returns the job with its PDF passwords replaced by placeholders, for API responses
+func (job Job) redacted() Job {
+	redacted, _ := job.withEncryption(func(e *PDFEncryption) error {
+		e.Password = secretStatus(e.Password)
+		e.OwnerPassword = secretStatus(e.OwnerPassword)
+		return nil
+	})
+	return redacted
+}
+
+// restoreSecrets replaces the placeholders posted back for the job's PDF passwords with the stored values
+func (job Job) restoreSecrets(stored Job) Job {
+	var storedEncryption PDFEncryption
+	if e := stored.pdfEncryption(); e != nil {
+		storedEncryption = *e
+	}
+	restored, _ := job.withEncryption(func(e *PDFEncryption) error {
+		if e.Password == secretPlaceholder {
+			e.Password = storedEncryption.Password
+		}
+		if e.OwnerPassword == secretPlaceholder {
+			e.OwnerPassword = storedEncryption.OwnerPassword
+		}
+		return nil
+	})
+	return restored
+}
+
+// sealSecrets returns the job with its PDF passwords encrypted for storage
+func (job Job) sealSecrets(key []byte) (Job, error) {
+	return job.withEncryption(func(e *PDFEncryption) error {
+		for _, secret := range []*string{&e.Password, &e.OwnerPassword} {
+			var err error
+			if *secret, err = encryptSecret(key, *secret); err != nil {
+				return fmt.Errorf("failed to encrypt PDF password of job %s: %w", job.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// openSecrets returns the job with the PDF passwords read from storage decrypted
+func (job Job) openSecrets(key []byte) (Job, error) {
+	return job.withEncryption(func(e *PDFEncryption) error {
+		for _, secret := range []*string{&e.Password, &e.OwnerPassword} {
+			var err error
+			if *secret, err = decryptSecret(key, *secret); err != nil {
+				return fmt.Errorf("failed to decrypt PDF password of job %s: %w", job.ID, err)
+			}
+		}
+		return nil
+	})
+}
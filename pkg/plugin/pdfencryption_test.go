@@ -0,0 +1,204 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func TestValidatePDFEncryption(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     Job
+		wantErr bool
+	}{
+		{name: "no encryption", job: Job{Format: "png"}},
+		{name: "fixed password", job: Job{Format: "pdf", PDF: &PDFOptions{Encryption: &PDFEncryption{Password: "s3cret", NoCopy: true}}}},
+		{name: "per recipient", job: Job{Format: "pdf", PDF: &PDFOptions{Encryption: &PDFEncryption{Password: "seed", PerRecipient: true}}}},
+		{name: "not a PDF", job: Job{Format: "png", PDF: &PDFOptions{Encryption: &PDFEncryption{Password: "s3cret"}}}, wantErr: true},
+		{name: "missing password", job: Job{Format: "pdf", PDF: &PDFOptions{Encryption: &PDFEncryption{OwnerPassword: "owner"}}}, wantErr: true},
+		{name: "placeholder password", job: Job{Format: "pdf", PDF: &PDFOptions{Encryption: &PDFEncryption{Password: secretPlaceholder}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.job.validatePDFOptions(); (err != nil) != tt.wantErr {
+				t.Errorf("validatePDFOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecipientPassword(t *testing.T) {
+	encryption := PDFEncryption{Password: "seed"}
+
+	password := encryption.recipientPassword("alice@example.com")
+	if len(password) != recipientPasswordLength {
+		t.Errorf("Expected a %d character password, got %q", recipientPasswordLength, password)
+	}
+	if got := encryption.recipientPassword(" Alice@Example.com "); got != password {
+		t.Errorf("Expected the password to ignore case and spaces, got %q and %q", password, got)
+	}
+	if encryption.recipientPassword("bob@example.com") == password {
+		t.Error("Expected recipients to have different passwords")
+	}
+	if (PDFEncryption{Password: "other"}).recipientPassword("alice@example.com") == password {
+		t.Error("Expected the password to depend on the job's secret")
+	}
+}
+
+// readEncryptedPDF opens an encrypted PDF with a password and returns its encryption dictionary
+func readEncryptedPDF(t *testing.T, document []byte, password string) (*model.Enc, error) {
+	t.Helper()
+	conf := model.NewAESConfiguration(password, password, 256)
+	ctx, err := api.ReadContext(bytes.NewReader(document), conf)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.E == nil {
+		t.Fatal("Expected the document to be encrypted")
+	}
+	return ctx.E, nil
+}
+
+func TestComposePDFEncryption(t *testing.T) {
+	sections := []pdfSection{{title: "Dashboard revenue", image: testPNG(t, 200, 100, color.White)}}
+
+	plain, err := composePDF(Job{}, sections, nil, "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(plain, []byte("/Encrypt")) {
+		t.Error("Expected reports without encryption settings to be left unprotected")
+	}
+
+	job := Job{Format: "pdf", PDF: &PDFOptions{Encryption: &PDFEncryption{Password: "s3cret", NoPrint: true, NoCopy: true}}}
+	encrypted, err := composePDF(job, sections, nil, "s3cret", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// AES-256: security handler version 5, revision 6, 256-bit keys
+	enc, err := readEncryptedPDF(t, encrypted, "s3cret")
+	if err != nil {
+		t.Fatalf("Expected the password to open the document: %v", err)
+	}
+	if enc.V != 5 || enc.R != 6 || enc.L != 256 {
+		t.Errorf("Expected AES-256 encryption (V 5, R 6, Length 256), got V %d, R %d, Length %d", enc.V, enc.R, enc.L)
+	}
+	if !bytes.HasPrefix(encrypted, []byte("%PDF-2.0")) {
+		t.Errorf("Expected a PDF 2.0 document, got %q", encrypted[:8])
+	}
+	if _, err := readEncryptedPDF(t, encrypted, "wrong"); err == nil {
+		t.Error("Expected another password not to open the document")
+	}
+
+	// Modifying and annotating are allowed, printing and copying are not
+	permissions := model.PermissionFlags(enc.P)
+	if permissions&(model.PermissionPrintRev3|model.PermissionExtract) != 0 {
+		t.Errorf("Expected the document to deny printing and copying, got permissions %b", permissions)
+	}
+	if permissions&(model.PermissionModify|model.PermissionModAnnFillForm) == 0 {
+		t.Errorf("Expected the document to allow modifying and annotating, got permissions %b", permissions)
+	}
+}
+
+func TestExecuteJobEncryptsPerRecipient(t *testing.T) {
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testPNG(t, 200, 100, color.White))
+	}))
+	defer grafana.Close()
+	smtpServer := startFakeSMTP(t)
+	port, _ := strconv.Atoi(smtpServer.port)
+
+	app := newTestApp(t)
+	app.config = Config{GrafanaURL: grafana.URL, SMTPHost: smtpServer.host, SMTPPort: port, SMTPFrom: "reports@example.com"}
+	job := Job{
+		ID:           "revenue",
+		DashboardUID: "abc",
+		Format:       "pdf",
+		Recipients:   []string{"alice@example.com", "bob@example.com"},
+		PDF:          &PDFOptions{Encryption: &PDFEncryption{Password: "seed", PerRecipient: true}},
+	}
+
+	if err := app.executeJob(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := smtpServer.received()
+	if len(messages) != 2 {
+		t.Fatalf("Expected one email per recipient, got %d", len(messages))
+	}
+	for i, recipient := range job.Recipients {
+		if !strings.Contains(messages[i], "To: "+recipient+"\r\n") {
+			t.Errorf("Expected email %d to be sent to %s only", i, recipient)
+		}
+		if !strings.Contains(messages[i], "application/pdf") {
+			t.Errorf("Expected email %d to have a PDF attachment", i)
+		}
+	}
+}
+
+func TestPDFPasswordsAPI(t *testing.T) {
+	app := newTestApp(t)
+	admin := &backend.User{Login: "admin", Role: roleAdmin}
+	editor := &backend.User{Login: "editor", Role: roleEditor}
+
+	resp := callResource(t, app, admin, http.MethodPost, "/jobs", `{"id": "revenue", "cron": "0 9 * * 1", "format": "pdf", "recipients": ["alice@example.com"],
+		"pdf": {"encryption": {"password": "seed", "ownerPassword": "owner", "perRecipient": true}}}`)
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("Expected job to be created, got %d: %s", resp.Status, resp.Body)
+	}
+	if bytes.Contains(resp.Body, []byte(`"seed"`)) || bytes.Contains(resp.Body, []byte(`"ownerPassword":"owner"`)) {
+		t.Errorf("Expected passwords to be redacted, got %s", resp.Body)
+	}
+
+	// Passwords are encrypted at rest
+	data, _ := os.ReadFile(app.jobsFile)
+	if bytes.Contains(data, []byte(`"seed"`)) || !bytes.Contains(data, []byte(encryptedPrefix)) {
+		t.Errorf("Expected passwords to be encrypted in the jobs file, got %s", data)
+	}
+
+	// Posting the placeholders back keeps the stored passwords
+	resp = callResource(t, app, admin, http.MethodGet, "/jobs/revenue", "")
+	resp = callResource(t, app, admin, http.MethodPut, "/jobs/revenue", string(resp.Body))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected job to be updated, got %d: %s", resp.Status, resp.Body)
+	}
+	if encryption := app.jobs["revenue"].pdfEncryption(); encryption.Password != "seed" || encryption.OwnerPassword != "owner" {
+		t.Errorf("Expected passwords to be kept, got %+v", encryption)
+	}
+
+	resp = callResource(t, app, admin, http.MethodGet, "/jobs/revenue/pdf-passwords", "")
+	var passwords []RecipientPassword
+	json.Unmarshal(resp.Body, &passwords)
+	want := PDFEncryption{Password: "seed"}.recipientPassword("alice@example.com")
+	if len(passwords) != 1 || passwords[0].Recipient != "alice@example.com" || passwords[0].Password != want {
+		t.Errorf("Unexpected passwords: %s", resp.Body)
+	}
+	if resp = callResource(t, app, editor, http.MethodGet, "/jobs/revenue/pdf-passwords", ""); resp.Status != http.StatusForbidden {
+		t.Errorf("Expected editors who don't own the job to be denied, got %d", resp.Status)
+	}
+
+	// Passwords survive a restart
+	reloaded := newTestApp(t)
+	reloaded.dataDir, reloaded.jobsFile = app.dataDir, app.jobsFile
+	if err := reloaded.loadJobs(); err != nil {
+		t.Fatal(err)
+	}
+	if encryption := reloaded.jobs["revenue"].pdfEncryption(); encryption.Password != "seed" {
+		t.Errorf("Expected the password to be decrypted on load, got %+v", encryption)
+	}
+}
@@ -0,0 +1,264 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"gopkg.in/yaml.v3"
+)
+
+// provisioningFile is the structure of a YAML provisioning file
+//
+// Example:
+//
+//	apiVersion: 1
+//	smtp:
+//	  host: smtp.example.com
+//	  password: ${SMTP_PASSWORD}
+//	jobs:
+//	  - id: daily-ops
+//	    cron: "0 9 * * *"
+//	    dashboardUid: abc123
+//	deleteJobs:
+//	  - id: legacy-report
+type provisioningFile struct {
+	APIVersion int              `json:"apiVersion"`
+	SMTP       *provisionedSMTP `json:"smtp,omitempty"`
+	Jobs       []Job            `json:"jobs"`
+	DeleteJobs []struct {
+		ID string `json:"id"`
+	} `json:"deleteJobs"`
+}
+
+// provisionedSMTP holds SMTP settings declared in provisioning files
+type provisionedSMTP struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+}
+
+// applyTo overrides the non-empty provisioned settings in config
+func (p *provisionedSMTP) applyTo(config *Config) {
+	if p.Host != "" {
+		config.SMTPHost = p.Host
+	}
+	if p.Port != 0 {
+		config.SMTPPort = p.Port
+	}
+	if p.User != "" {
+		config.SMTPUser = p.User
+	}
+	if p.Password != "" {
+		config.SMTPPassword = p.Password
+	}
+	if p.From != "" {
+		config.SMTPFrom = p.From
+	}
+}
+
+// keepStored replaces the settings of config that are provisioned with those of stored, so that
+// values posted through the API for provisioned settings are not saved
+func (p *provisionedSMTP) keepStored(config *Config, stored Config) {
+	if p.Host != "" {
+		config.SMTPHost = stored.SMTPHost
+	}
+	if p.Port != 0 {
+		config.SMTPPort = stored.SMTPPort
+	}
+	if p.User != "" {
+		config.SMTPUser = stored.SMTPUser
+	}
+	if p.Password != "" {
+		config.SMTPPassword = stored.SMTPPassword
+	}
+	if p.From != "" {
+		config.SMTPFrom = stored.SMTPFrom
+	}
+}
+
+// applyProvisioning reads all provisioning files and syncs jobs and SMTP settings with them.
+// Jobs are only updated in memory and on disk; callers are responsible for (re)scheduling.
+func (app *App) applyProvisioning() error {
+	files, err := readProvisioningFiles(app.provisioningDir)
+	if err != nil {
+		return err
+	}
+
+	declared := make(map[string]Job)
+	deleted := make(map[string]bool)
+	invalid := make(map[string]bool)
+	var smtp *provisionedSMTP
+
+	for _, file := range files {
+		for _, job := range file.Jobs {
+			// An invalid job is left out rather than failing the sync of the other jobs
+			if job.ID == "" {
+				log.DefaultLogger.Error("Skipping provisioned job without id")
+				continue
+			}
+			if err := app.validateJob(job); err != nil {
+				log.DefaultLogger.Error("Skipping invalid provisioned job", "id", job.ID, "error", err)
+				invalid[job.ID] = true
+				continue
+			}
+			if _, dup := declared[job.ID]; dup {
+				log.DefaultLogger.Warn("Job provisioned more than once, using last definition", "id", job.ID)
+			}
+			job.Provisioned = true
+			declared[job.ID] = job
+		}
+		for _, entry := range file.DeleteJobs {
+			deleted[entry.ID] = true
+		}
+		if file.SMTP != nil {
+			smtp = file.SMTP
+		}
+	}
+
+	// Provisioned SMTP settings are applied when the configuration is read, so they are never saved
+	app.configMu.Lock()
+	app.provisionedSMTP = smtp
+	app.configMu.Unlock()
+
+	changed := false
+
+	app.mu.Lock()
+	for id, job := range app.jobs {
+		// Provisioned jobs that are no longer declared are removed with their file. A job whose new
+		// definition is invalid keeps its previous one.
+		_, stillDeclared := declared[id]
+		if deleted[id] || (job.Provisioned && !stillDeclared && !invalid[id]) {
+			delete(app.jobs, id)
+			changed = true
+			log.DefaultLogger.Info("Removed provisioned job", "id", id)
+		}
+	}
+	for id, job := range declared {
+		if deleted[id] {
+			continue
+		}
+		// Self-service subscriptions survive provisioning syncs
+		existing, ok := app.jobs[id]
+		if ok {
+			job.Subscribers = existing.Subscribers
+		}
+		// Provisioning files stay declarative, so a one-shot time already past is reported but not rejected
+		if err := job.validateRunAt(existing.RunAt, time.Now()); err != nil && !ok {
+			log.DefaultLogger.Warn("Provisioned one-shot job will not run", "id", id, "error", err)
+		}
+		app.jobs[id] = job
+		changed = true
+	}
+	app.mu.Unlock()
+
+	if len(files) > 0 {
+		log.DefaultLogger.Info("Applied provisioning", "files", len(files), "jobs", len(declared))
+	}
+
+	if changed {
+		return app.saveJobs()
+	}
+	return nil
+}
+
+// readProvisioningFiles parses every YAML file in dir, in name order. A missing directory is not an error.
+func readProvisioningFiles(dir string) ([]provisioningFile, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provisioning directory: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	files := make([]provisioningFile, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read provisioning file %s: %w", name, err)
+		}
+		file, err := parseProvisioningFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse provisioning file %s: %w", name, err)
+		}
+		files = append(files, *file)
+	}
+
+	return files, nil
+}
+
+// parseProvisioningFile decodes a YAML provisioning file, interpolating ${VAR} references in string values
+func parseProvisioningFile(data []byte) (*provisioningFile, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	// Round-trip through JSON so the Job JSON tags and backward compatibility handling apply
+	converted, err := json.Marshal(interpolateEnv(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	var file provisioningFile
+	if err := json.Unmarshal(converted, &file); err != nil {
+		return nil, err
+	}
+
+	if file.APIVersion > 1 {
+		return nil, fmt.Errorf("unsupported apiVersion %d", file.APIVersion)
+	}
+
+	return &file, nil
+}
+
+// envReferencePattern matches the ${VAR} references of provisioning files, and their $${VAR} escapes
+var envReferencePattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// interpolateEnv expands ${VAR} in all string values; $${VAR} produces a literal ${VAR}, and other
+// dollar signs are left as they are
+func interpolateEnv(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return envReferencePattern.ReplaceAllStringFunc(v, func(reference string) string {
+			if strings.HasPrefix(reference, "$$") {
+				return reference[1:]
+			}
+			return os.Getenv(reference[2 : len(reference)-1])
+		})
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = interpolateEnv(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = interpolateEnv(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
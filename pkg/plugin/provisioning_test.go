@@ -0,0 +1,206 @@
+package plugin
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func writeProvisioningFile(t *testing.T, app *App, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(app.provisioningDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(app.provisioningDir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyProvisioning(t *testing.T) {
+	t.Setenv("TEST_SMTP_PASSWORD", "s3cret")
+	t.Setenv("TEST_REGION", "us-east")
+
+	app := newTestApp(t)
+	app.jobs["legacy"] = Job{ID: "legacy", Cron: "0 8 * * *"}
+	app.jobs["manual"] = Job{ID: "manual", Cron: "0 8 * * *"}
+
+	writeProvisioningFile(t, app, "reports.yaml", `
+apiVersion: 1
+smtp:
+  host: smtp.example.com
+  password: ${TEST_SMTP_PASSWORD}
+jobs:
+  - id: daily-ops
+    cron: "0 9 * * *"
+    dashboardUid: abc123
+    subject: "$5k revenue for $${REGION}"
+    body: "Costs $$100 in ${TEST_REGION}"
+    variables:
+      region: us-east
+deleteJobs:
+  - id: legacy
+`)
+
+	if err := app.applyProvisioning(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	job, ok := app.jobs["daily-ops"]
+	if !ok {
+		t.Fatal("Expected provisioned job to be created")
+	}
+	if !job.Provisioned {
+		t.Error("Expected job to be marked as provisioned")
+	}
+	if job.Subject != "$5k revenue for ${REGION}" {
+		t.Errorf("Expected dollar signs outside of references to be kept, got %q", job.Subject)
+	}
+	if job.Body != "Costs $$100 in us-east" {
+		t.Errorf("Expected the region to be read from the environment, got %q", job.Body)
+	}
+	if got := job.Variables["region"]; len(got) != 1 || got[0] != "us-east" {
+		t.Errorf("Expected region variable, got %v", got)
+	}
+	if _, ok := app.jobs["legacy"]; ok {
+		t.Error("Expected legacy job to be deleted")
+	}
+	if _, ok := app.jobs["manual"]; !ok {
+		t.Error("Expected manual job to be kept")
+	}
+	if config := app.currentConfig(); config.SMTPHost != "smtp.example.com" || config.SMTPPassword != "s3cret" {
+		t.Errorf("Expected provisioned SMTP settings, got %+v", config)
+	}
+
+	// Removing the file removes the jobs it declared
+	os.Remove(filepath.Join(app.provisioningDir, "reports.yaml"))
+	if err := app.applyProvisioning(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := app.jobs["daily-ops"]; ok {
+		t.Error("Expected undeclared provisioned job to be removed")
+	}
+	if config := app.currentConfig(); config.SMTPHost != "" || config.SMTPPassword != "" {
+		t.Errorf("Expected SMTP settings to no longer be provisioned, got %+v", config)
+	}
+}
+
+func TestProvisionedSMTPIsNotSaved(t *testing.T) {
+	t.Setenv("TEST_SMTP_PASSWORD", "s3cret")
+
+	app := newTestApp(t)
+	app.config = Config{SMTPHost: "stored.example.com", SMTPPassword: "stored-pass"}
+
+	writeProvisioningFile(t, app, "smtp.yaml", `
+apiVersion: 1
+smtp:
+  host: smtp.example.com
+  password: ${TEST_SMTP_PASSWORD}
+`)
+	if err := app.applyProvisioning(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The settings page posts back the provisioned values it was shown
+	admin := &backend.User{Login: "admin", Role: roleAdmin}
+	resp := callResource(t, app, admin, http.MethodPost, "/config", `{"grafanaUrl": "http://grafana:3000", "smtpHost": "smtp.example.com", "smtpPassword": "********", "smtpFrom": "reports@example.com"}`)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+
+	data, err := os.ReadFile(app.configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "smtp.example.com") {
+		t.Errorf("Expected the provisioned host not to be saved, got %s", data)
+	}
+	if app.config.SMTPHost != "stored.example.com" || app.config.SMTPPassword != "stored-pass" || app.config.SMTPFrom != "reports@example.com" {
+		t.Errorf("Expected only settings that are not provisioned to be updated, got %+v", app.config)
+	}
+	if config := app.currentConfig(); config.SMTPHost != "smtp.example.com" || config.SMTPPassword != "s3cret" {
+		t.Errorf("Expected provisioned SMTP settings to be in effect, got %+v", config)
+	}
+}
+
+func TestProvisionedJobsAreReadOnly(t *testing.T) {
+	app := newTestApp(t)
+	app.jobs["daily-ops"] = Job{ID: "daily-ops", Cron: "0 9 * * *", Provisioned: true}
+
+	admin := &backend.User{Login: "admin", Role: roleAdmin}
+
+	resp := callResource(t, app, admin, http.MethodPut, "/jobs/daily-ops", `{"cron": "0 10 * * *"}`)
+	if resp.Status != http.StatusForbidden || !strings.Contains(string(resp.Body), "provisioned") {
+		t.Errorf("Expected update to be forbidden, got %d: %s", resp.Status, resp.Body)
+	}
+
+	resp = callResource(t, app, admin, http.MethodDelete, "/jobs/daily-ops", "")
+	if resp.Status != http.StatusForbidden || !strings.Contains(string(resp.Body), "provisioned") {
+		t.Errorf("Expected delete to be forbidden, got %d: %s", resp.Status, resp.Body)
+	}
+
+	if app.jobs["daily-ops"].Cron != "0 9 * * *" {
+		t.Error("Expected provisioned job to be unchanged")
+	}
+}
+
+func TestParseProvisioningFileRejectsUnknownVersion(t *testing.T) {
+	if _, err := parseProvisioningFile([]byte("apiVersion: 2\n")); err == nil {
+		t.Error("Expected error for unsupported apiVersion")
+	}
+}
+
+func TestInvalidJobRejectedEverywhere(t *testing.T) {
+	app := newTestApp(t)
+	admin := &backend.User{Login: "admin", Role: roleAdmin}
+	const want = "archive retention rules must not be negative"
+
+	resp := callResource(t, app, admin, http.MethodPost, "/jobs", `{"id": "daily", "cron": "0 9 * * *", "archive": {"keepRuns": -1}}`)
+	if resp.Status != http.StatusBadRequest || !strings.Contains(string(resp.Body), want) {
+		t.Errorf("Expected the API to reject the job, got %d: %s", resp.Status, resp.Body)
+	}
+
+	bundle := &JobBundle{Version: bundleVersion, Jobs: []Job{{ID: "daily", Cron: "0 9 * * *", Archive: &ArchiveOptions{KeepRuns: -1}}}}
+	results, err := app.importBundle(bundle, conflictSkip, nil, "admin", false)
+	if err != nil || len(results) != 1 || results[0].Action != "invalid" || results[0].Error != want {
+		t.Errorf("Expected the import to reject the job, got %+v, %v", results, err)
+	}
+
+	writeProvisioningFile(t, app, "reports.yaml", `
+apiVersion: 1
+jobs:
+  - id: daily
+    cron: "0 9 * * *"
+    archive:
+      keepRuns: -1
+  - cron: "0 9 * * *"
+  - id: weekly
+    cron: "0 9 * * 1"
+`)
+	// The invalid jobs are left out, the others are still provisioned
+	if err := app.applyProvisioning(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := app.jobs["daily"]; ok || len(app.jobs) != 1 || !app.jobs["weekly"].Provisioned {
+		t.Errorf("Expected only the valid job to be created, got %v", app.jobs)
+	}
+
+	// A provisioned job whose new definition is invalid keeps its previous one
+	writeProvisioningFile(t, app, "reports.yaml", `
+apiVersion: 1
+jobs:
+  - id: weekly
+    cron: "0 9 * * 1"
+    archive:
+      keepRuns: -1
+`)
+	if err := app.applyProvisioning(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if job, ok := app.jobs["weekly"]; !ok || job.Archive != nil {
+		t.Errorf("Expected the previous definition to be kept, got %+v", job)
+	}
+}
@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Themes and kiosk modes of RenderOptions
+const (
+	themeLight = "light"
+	themeDark  = "dark"
+
+	kioskFull = "full" // hide the navigation (default)
+	kioskTV   = "tv"   // hide the navigation but keep the dashboard controls
+	kioskOff  = "off"  // render the dashboard with Grafana's navigation
+)
+
+// maxDeviceScaleFactor bounds DeviceScaleFactor, since the renderer allocates an image of width x height x factor²
+const maxDeviceScaleFactor = 4
+
+// RenderOptions controls how the image renderer draws a job's dashboard or panel
+type RenderOptions struct {
+	Theme             string  `json:"theme,omitempty"`             // light or dark, default: the organization's theme
+	Kiosk             string  `json:"kiosk,omitempty"`             // Dashboards only: full (default), tv or off
+	HideControls      bool    `json:"hideControls,omitempty"`      // Dashboards only: hide the time picker, variables and links
+	TimeoutSeconds    int     `json:"timeoutSeconds,omitempty"`    // Renderer timeout, default: Grafana's (60s)
+	DeviceScaleFactor float64 `json:"deviceScaleFactor,omitempty"` // Overrides Scale, allowing fractional factors such as 1.5
+	OrgID             int64   `json:"orgId,omitempty"`             // Organization of the dashboard, default: the API key's
+}
+
+// renderOptions returns the job's render options, or the defaults
+func (job Job) renderOptions() RenderOptions {
+	if job.RenderOptions == nil {
+		return RenderOptions{}
+	}
+	return *job.RenderOptions
+}
+
+// validateRenderOptions checks the job's render options
+func (job Job) validateRenderOptions() error {
+	options := job.renderOptions()
+	switch options.Theme {
+	case "", themeLight, themeDark:
+	default:
+		return fmt.Errorf("invalid theme %q (expected %s or %s)", options.Theme, themeLight, themeDark)
+	}
+	switch options.Kiosk {
+	case "", kioskFull, kioskTV, kioskOff:
+	default:
+		return fmt.Errorf("invalid kiosk mode %q (expected %s, %s or %s)", options.Kiosk, kioskFull, kioskTV, kioskOff)
+	}
+	if options.TimeoutSeconds < 0 {
+		return fmt.Errorf("render timeoutSeconds must not be negative")
+	}
+	if timeout := job.runTimeout().Seconds(); float64(options.TimeoutSeconds) > timeout {
+		return fmt.Errorf("render timeoutSeconds (%d) exceeds the job timeout (%.0fs)", options.TimeoutSeconds, timeout)
+	}
+	if options.DeviceScaleFactor < 0 || options.DeviceScaleFactor > maxDeviceScaleFactor {
+		return fmt.Errorf("deviceScaleFactor must be between 0 and %d", maxDeviceScaleFactor)
+	}
+	if options.OrgID < 0 {
+		return fmt.Errorf("orgId must not be negative")
+	}
+	return nil
+}
+
+// params returns the renderer URL parameters that apply to both dashboard and panel renders
+func (o RenderOptions) params() string {
+	var params string
+	if o.Theme != "" {
+		params += "&theme=" + o.Theme
+	}
+	if o.OrgID > 0 {
+		params += "&orgId=" + strconv.FormatInt(o.OrgID, 10)
+	}
+	if o.TimeoutSeconds > 0 {
+		params += "&timeout=" + strconv.Itoa(o.TimeoutSeconds)
+	}
+	return params
+}
+
+// dashboardParams returns the renderer URL parameters that only apply to full dashboard renders
+func (o RenderOptions) dashboardParams() string {
+	var params string
+	switch o.Kiosk {
+	case "", kioskFull:
+		params += "&kiosk"
+	case kioskTV:
+		params += "&kiosk=tv"
+	}
+	if o.HideControls {
+		params += "&_dash.hideTimePicker&_dash.hideVariables&_dash.hideLinks"
+	}
+	return params
+}
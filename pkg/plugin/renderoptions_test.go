@@ -0,0 +1,93 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildRenderURL(t *testing.T) {
+	app := newTestApp(t)
+	panelID := 4
+
+	tests := []struct {
+		name    string
+		job     Job
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "dashboard defaults",
+			job:     Job{DashboardUID: "abc", Slug: "ops", From: "now-1h", To: "now", Width: 1000, Height: 500, Scale: 1},
+			want:    []string{"/render/d/abc/ops?", "&scale=1&", "&kiosk", "&tz=UTC"},
+			notWant: []string{"theme=", "orgId=", "timeout=", "_dash."},
+		},
+		{
+			name: "dashboard with options",
+			job: Job{DashboardUID: "abc", Slug: "ops", Scale: 1, RenderOptions: &RenderOptions{
+				Theme: themeLight, Kiosk: kioskTV, HideControls: true, TimeoutSeconds: 120, DeviceScaleFactor: 1.5, OrgID: 3,
+			}},
+			want:    []string{"&scale=1.5&", "&kiosk=tv", "&_dash.hideTimePicker&_dash.hideVariables&_dash.hideLinks", "&theme=light", "&orgId=3", "&timeout=120"},
+			notWant: []string{"&scale=1&"},
+		},
+		{
+			name:    "dashboard in a time zone",
+			job:     Job{DashboardUID: "abc", Slug: "ops", Timezone: "Europe/Paris"},
+			want:    []string{"&tz=Europe%2FParis"},
+			notWant: []string{"&tz=UTC"},
+		},
+		{
+			name:    "dashboard without kiosk",
+			job:     Job{DashboardUID: "abc", Slug: "ops", RenderOptions: &RenderOptions{Kiosk: kioskOff}},
+			notWant: []string{"kiosk"},
+		},
+		{
+			name: "panel",
+			job: Job{DashboardUID: "abc", Slug: "ops", PanelID: &panelID, Scale: 2, RenderOptions: &RenderOptions{
+				Theme: themeDark, Kiosk: kioskTV, HideControls: true, OrgID: 3,
+			}},
+			want:    []string{"/render/d-solo/abc/ops?panelId=4&", "&scale=2&", "&theme=dark", "&orgId=3"},
+			notWant: []string{"kiosk", "_dash."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := app.buildRenderURL("http://grafana:3000", tt.job)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Expected %q in %s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("Did not expect %q in %s", notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateRenderOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *RenderOptions
+		wantErr bool
+	}{
+		{name: "no options"},
+		{name: "all options", options: &RenderOptions{Theme: themeDark, Kiosk: kioskFull, HideControls: true, TimeoutSeconds: 60, DeviceScaleFactor: 2, OrgID: 1}},
+		{name: "unknown theme", options: &RenderOptions{Theme: "solarized"}, wantErr: true},
+		{name: "unknown kiosk mode", options: &RenderOptions{Kiosk: "fullscreen"}, wantErr: true},
+		{name: "negative timeout", options: &RenderOptions{TimeoutSeconds: -1}, wantErr: true},
+		{name: "timeout above job timeout", options: &RenderOptions{TimeoutSeconds: 600}, wantErr: true},
+		{name: "huge scale", options: &RenderOptions{DeviceScaleFactor: 10}, wantErr: true},
+		{name: "negative org", options: &RenderOptions{OrgID: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := Job{RenderOptions: tt.options}
+			if err := job.validateRenderOptions(); (err != nil) != tt.wantErr {
+				t.Errorf("validateRenderOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
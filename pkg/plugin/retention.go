@@ -0,0 +1,175 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// archiveJanitorInterval is how often the janitor applies the retention rules of the archive
+const archiveJanitorInterval = time.Hour
+
+// ArchiveOptions configures which reports of a job are archived and how long they are kept
+type ArchiveOptions struct {
+	Enabled   bool `json:"enabled,omitempty"`   // Archive the reports of every run, not only those sent as links
+	KeepRuns  int  `json:"keepRuns,omitempty"`  // Number of most recent runs kept
+	KeepDays  int  `json:"keepDays,omitempty"`  // Days runs are kept
+	MaxSizeMB int  `json:"maxSizeMB,omitempty"` // Total size of the job's archive; the oldest runs are removed first
+}
+
+// archivesRuns reports whether the reports of every run of the job are archived
+func (job Job) archivesRuns() bool {
+	return job.Archive != nil && job.Archive.Enabled
+}
+
+// validateArchive checks the job's archive options
+func (job Job) validateArchive() error {
+	if job.Archive == nil {
+		return nil
+	}
+	if job.Archive.KeepRuns < 0 || job.Archive.KeepDays < 0 || job.Archive.MaxSizeMB < 0 {
+		return fmt.Errorf("archive retention rules must not be negative")
+	}
+	return nil
+}
+
+// archiveReport keeps the files delivered by a run of a job that archives every run. Failing to
+// archive them does not fail the run, as the report was delivered.
+func (app *App) archiveReport(ctx context.Context, job Job, files []reportFile) {
+	if !job.archivesRuns() {
+		return
+	}
+	runID := archiveRunID(ctx)
+	now := time.Now()
+	for _, file := range files {
+		if _, err := app.storeReport(job.ID, runID, file.name, file.data, now); err != nil {
+			log.DefaultLogger.Error("Failed to archive report", "id", job.ID, "run", runID, "error", err)
+			return
+		}
+	}
+}
+
+// expiredRuns returns the runs the retention rules remove, from runs sorted most recent first.
+// The most recent run is kept by the size rule, so that a large report can still be downloaded,
+// and runs are kept until their download links expire, so that links already sent keep working.
+func (options ArchiveOptions) expiredRuns(runs []ArchivedRun, now time.Time) []ArchivedRun {
+	var expired []ArchivedRun
+	var total int64
+	for i, run := range runs {
+		total += run.Size
+		if run.LinksExpireAt != nil && run.LinksExpireAt.After(now) {
+			continue
+		}
+		switch {
+		case options.KeepRuns > 0 && i >= options.KeepRuns,
+			options.KeepDays > 0 && now.Sub(run.CreatedAt) > time.Duration(options.KeepDays)*24*time.Hour,
+			options.MaxSizeMB > 0 && i > 0 && total > int64(options.MaxSizeMB)<<20:
+			expired = append(expired, run)
+		}
+	}
+	return expired
+}
+
+// downloadsExpired reports whether the download links to a run's files have expired. Runs that do
+// not record when their links expire are kept for the default link duration.
+func (run ArchivedRun) downloadsExpired(now time.Time, ttl time.Duration) bool {
+	if run.LinksExpireAt != nil {
+		return run.LinksExpireAt.Before(now)
+	}
+	return now.Sub(run.CreatedAt) > ttl
+}
+
+// enforceRetention walks every job directory of the archive and removes the runs that are no longer
+// kept. The retention rules of a job apply to all of its runs. Runs of jobs that do not archive every
+// run, including jobs that no longer exist, were archived for download links and also go once their
+// links have expired.
+func (app *App) enforceRetention(now time.Time) {
+	app.mu.RLock()
+	rules := make(map[string]ArchiveOptions)
+	archived := make(map[string]bool)
+	for id, job := range app.jobs {
+		if job.Archive != nil {
+			rules[archiveDirName(id)] = *job.Archive
+		}
+		if job.archivesRuns() {
+			archived[archiveDirName(id)] = true
+		}
+	}
+	app.mu.RUnlock()
+	ttl := app.downloadLinkTTL()
+
+	entries, err := os.ReadDir(app.archiveDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.DefaultLogger.Error("Failed to read archive directory", "error", err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		jobDir := entry.Name()
+		runs, err := app.archivedRuns(jobDir)
+		if err != nil {
+			log.DefaultLogger.Error("Failed to list archived runs", "dir", jobDir, "error", err)
+			continue
+		}
+
+		expired := make(map[string]bool)
+		for _, run := range rules[jobDir].expiredRuns(runs, now) {
+			expired[run.dir] = true
+		}
+		for _, run := range runs {
+			if !archived[jobDir] && run.downloadsExpired(now, ttl) {
+				expired[run.dir] = true
+			}
+		}
+		for _, run := range runs {
+			if expired[run.dir] {
+				removeArchivedRun(jobDir, run)
+			}
+		}
+	}
+}
+
+// removeArchivedRun removes the files of an archived run
+func removeArchivedRun(jobDir string, run ArchivedRun) {
+	if err := os.RemoveAll(run.dir); err != nil {
+		log.DefaultLogger.Error("Failed to remove archived run", "run", run.RunID, "error", err)
+		return
+	}
+	// Date directories go once their last run is removed
+	os.Remove(filepath.Dir(run.dir))
+	log.DefaultLogger.Info("Removed archived run", "dir", jobDir, "run", run.RunID, "date", run.Date)
+}
+
+// startArchiveJanitor applies the retention of the archive now and then every
+// archiveJanitorInterval, until Dispose stops it
+func (app *App) startArchiveJanitor() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	app.stopJanitor = func() {
+		cancel()
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(archiveJanitorInterval)
+		defer ticker.Stop()
+
+		for {
+			app.enforceRetention(time.Now())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,187 @@
+package plugin
+
+import (
+	"context"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestValidateArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     Job
+		wantErr bool
+	}{
+		{name: "no options", job: Job{}},
+		{name: "retention rules", job: Job{Archive: &ArchiveOptions{Enabled: true, KeepRuns: 10, KeepDays: 30, MaxSizeMB: 500}}},
+		{name: "negative runs", job: Job{Archive: &ArchiveOptions{KeepRuns: -1}}, wantErr: true},
+		{name: "negative days", job: Job{Archive: &ArchiveOptions{KeepDays: -1}}, wantErr: true},
+		{name: "negative size", job: Job{Archive: &ArchiveOptions{MaxSizeMB: -1}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.job.validateArchive(); (err != nil) != tt.wantErr {
+				t.Errorf("validateArchive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpiredRuns(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	runs := []ArchivedRun{
+		{RunID: "run-4", CreatedAt: now.Add(-time.Hour), Size: 3 << 20},
+		{RunID: "run-3", CreatedAt: now.Add(-48 * time.Hour), Size: 1 << 20},
+		{RunID: "run-2", CreatedAt: now.Add(-72 * time.Hour), Size: 1 << 20},
+		{RunID: "run-1", CreatedAt: now.Add(-30 * 24 * time.Hour), Size: 1 << 20},
+	}
+
+	tests := []struct {
+		name    string
+		options ArchiveOptions
+		want    []string
+	}{
+		{name: "no rules"},
+		{name: "keep runs", options: ArchiveOptions{KeepRuns: 2}, want: []string{"run-2", "run-1"}},
+		{name: "keep days", options: ArchiveOptions{KeepDays: 2}, want: []string{"run-2", "run-1"}},
+		{name: "max size", options: ArchiveOptions{MaxSizeMB: 4}, want: []string{"run-2", "run-1"}},
+		{name: "max size keeps the last run", options: ArchiveOptions{MaxSizeMB: 2}, want: []string{"run-3", "run-2", "run-1"}},
+		{name: "combined", options: ArchiveOptions{KeepRuns: 3, KeepDays: 7}, want: []string{"run-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, run := range tt.options.expiredRuns(runs, now) {
+				got = append(got, run.RunID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expiredRuns() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("expiredRuns() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	// Runs whose download links are still valid are kept whatever the rules
+	linksExpireAt := now.Add(time.Hour)
+	linked := append([]ArchivedRun(nil), runs...)
+	linked[2].LinksExpireAt = &linksExpireAt
+	expired := ArchiveOptions{KeepRuns: 1, KeepDays: 1, MaxSizeMB: 1}.expiredRuns(linked, now)
+	if len(expired) != 2 || expired[0].RunID != "run-3" || expired[1].RunID != "run-1" {
+		t.Errorf("Expected the run with valid links to be kept, got %+v", expired)
+	}
+}
+
+// storeTestRun archives a file for a run and dates it
+func storeTestRun(t *testing.T, app *App, jobID, runID string, at time.Time) {
+	t.Helper()
+	if _, err := app.storeReport(jobID, runID, "report.pdf", []byte("%PDF"), at); err != nil {
+		t.Fatal(err)
+	}
+	path, err := app.findReport(runID, "report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, at, at)
+}
+
+func TestEnforceRetention(t *testing.T) {
+	app := newTestApp(t)
+	now := time.Now()
+	app.jobs["ops"] = Job{ID: "ops", Archive: &ArchiveOptions{KeepRuns: 2}}
+	app.jobs["sales"] = Job{ID: "sales", Archive: &ArchiveOptions{Enabled: true}}
+	for i, age := range []time.Duration{72 * time.Hour, 48 * time.Hour, time.Hour} {
+		storeTestRun(t, app, "ops", "ops-"+strconv.Itoa(i), now.Add(-age))
+		storeTestRun(t, app, "sales", "sales-"+strconv.Itoa(i), now.Add(-age))
+	}
+	storeTestRun(t, app, "deleted", "deleted-0", now.Add(-72*time.Hour))
+	storeTestRun(t, app, "deleted", "deleted-1", now.Add(-8*24*time.Hour))
+
+	app.enforceRetention(now)
+
+	runs, err := app.archivedRuns("ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].RunID != "ops-2" || runs[1].RunID != "ops-1" {
+		t.Errorf("Expected the 2 most recent runs to be kept, got %+v", runs)
+	}
+	// The date directory of the removed run goes with it
+	if _, err := os.Stat(filepath.Join(app.archiveDir, "ops", now.Add(-72*time.Hour).UTC().Format("2006-01-02"))); !os.IsNotExist(err) {
+		t.Errorf("Expected the empty date directory to be removed, got %v", err)
+	}
+
+	// Jobs archiving every run without rules keep their reports
+	if runs, _ := app.archivedRuns("sales"); len(runs) != 3 {
+		t.Errorf("Expected every run of a job without rules to be kept, got %d", len(runs))
+	}
+	// Other reports were archived for download links, which last 7 days by default
+	if runs, _ := app.archivedRuns("deleted"); len(runs) != 1 || runs[0].RunID != "deleted-0" {
+		t.Errorf("Expected the reports of a deleted job to be removed with their links, got %+v", runs)
+	}
+}
+
+func TestArchiveJanitor(t *testing.T) {
+	app := newTestApp(t)
+	app.jobs["ops"] = Job{ID: "ops", Archive: &ArchiveOptions{KeepDays: 1}}
+	storeTestRun(t, app, "ops", "run-1", time.Now().Add(-72*time.Hour))
+
+	app.startArchiveJanitor()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if runs, _ := app.archivedRuns("ops"); len(runs) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the janitor to remove the expired run on start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		app.stopJanitor()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the janitor to stop")
+	}
+}
+
+func TestExecuteJobArchivesRuns(t *testing.T) {
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testPNG(t, 100, 100, color.White))
+	}))
+	defer grafana.Close()
+	smtpServer := startFakeSMTP(t)
+	port, _ := strconv.Atoi(smtpServer.port)
+
+	app := newTestApp(t)
+	app.config = Config{GrafanaURL: grafana.URL, SMTPHost: smtpServer.host, SMTPPort: port, SMTPFrom: "reports@example.com"}
+	job := Job{ID: "ops", DashboardUID: "abc", Format: "png", Recipients: []string{"ops@example.com"}, Archive: &ArchiveOptions{Enabled: true}}
+
+	run := app.runJob(context.Background(), job, triggerManual)
+	if run.Status != runSucceeded {
+		t.Fatalf("Expected the run to succeed, got %s: %s", run.Status, run.Error)
+	}
+	if len(smtpServer.received()) != 1 {
+		t.Fatal("Expected the report to be emailed")
+	}
+	runs, err := app.archivedRuns("ops")
+	if err != nil || len(runs) != 1 || runs[0].RunID != run.ID || len(runs[0].Files) != 1 {
+		t.Fatalf("Expected the run's report to be archived, got %+v, %v", runs, err)
+	}
+}
@@ -0,0 +1,435 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+const (
+	// defaultRunTimeout bounds a whole run (render and delivery) when the job sets no timeout
+	defaultRunTimeout = 5 * time.Minute
+	// defaultShutdownGrace is how long Dispose waits for runs in progress before aborting them
+	defaultShutdownGrace = 30 * time.Second
+	// shutdownAbortWait is how long Dispose waits for aborted runs to record their state
+	shutdownAbortWait = 5 * time.Second
+	// maxRunHistory is the number of finished runs kept in the runs file
+	maxRunHistory = 100
+)
+
+// Run triggers
+const (
+	triggerSchedule = "schedule"
+	triggerManual   = "manual"
+	triggerResume   = "resume"
+)
+
+// Run statuses
+const (
+	runRunning   = "running"
+	runSucceeded = "succeeded"
+	runFailed    = "failed"
+	runCancelled = "cancelled"
+	runAborted   = "aborted" // interrupted by a restart
+)
+
+var (
+	// errRunCancelled is the cancellation cause of runs cancelled through the API
+	errRunCancelled = errors.New("run cancelled by user")
+	// errShutdown is the cancellation cause of runs aborted because the plugin is shutting down
+	errShutdown = errors.New("run aborted by plugin shutdown")
+)
+
+// Run is a single execution of a job
+type Run struct {
+	ID           string     `json:"id"`
+	JobID        string     `json:"jobId"`
+	Trigger      string     `json:"trigger"`
+	Status       string     `json:"status"`
+	StartedAt    time.Time  `json:"startedAt"`
+	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	ResumedFrom  string     `json:"resumedFrom,omitempty"`  // ID of the interrupted run this run resumes
+	ScheduledFor *time.Time `json:"scheduledFor,omitempty"` // Occurrence of the schedule the run is for
+}
+
+// runsState is the content of the runs file
+type runsState struct {
+	InProgress []Run `json:"inProgress"`
+	Recent     []Run `json:"recent"`
+}
+
+// liveRuns holds the IDs of runs in progress in this process. A new app instance is created before the
+// previous one is disposed when the plugin settings change, and must not recover runs that are still going.
+var liveRuns sync.Map
+
+// runIDKey is the context key of the ID of the run a job is executed in
+type runIDKey struct{}
+
+// runIDFromContext returns the ID of the run executing in ctx, or "" outside of runs
+func runIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// activeRun is a run in progress, with the function that cancels it
+type activeRun struct {
+	run    Run
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+// runTimeout returns the total time allowed for a run of the job
+func (job Job) runTimeout() time.Duration {
+	if job.TimeoutSeconds > 0 {
+		return time.Duration(job.TimeoutSeconds) * time.Second
+	}
+	return defaultRunTimeout
+}
+
+// startRun registers a new run of the job. The run's context keeps the values (such as the trace)
+// of parent but not its cancellation; it is cancelled through cancelRun, on Dispose, or when the job's timeout passes.
+func (app *App) startRun(parent context.Context, job Job, trigger string) *activeRun {
+	id := fmt.Sprintf("run-%d", time.Now().UnixNano())
+	ctx, cancel := context.WithCancelCause(context.WithValue(context.WithoutCancel(parent), runIDKey{}, id))
+	timeout := job.runTimeout()
+	ctx, cancelTimeout := context.WithTimeoutCause(ctx, timeout, fmt.Errorf("run timed out after %s", timeout))
+
+	active := &activeRun{
+		run: Run{
+			ID:        id,
+			JobID:     job.ID,
+			Trigger:   trigger,
+			Status:    runRunning,
+			StartedAt: time.Now().UTC(),
+		},
+		ctx: ctx,
+		cancel: func(cause error) {
+			cancel(cause)
+			cancelTimeout()
+		},
+	}
+
+	liveRuns.Store(active.run.ID, true)
+
+	app.runsMu.Lock()
+	app.runs[active.run.ID] = active
+	app.saveRunsLocked()
+	app.runsMu.Unlock()
+
+	return active
+}
+
+// updateRun changes the recorded details of a run in progress
+func (app *App) updateRun(active *activeRun, update func(run *Run)) {
+	app.runsMu.Lock()
+	defer app.runsMu.Unlock()
+
+	update(&active.run)
+	app.saveRunsLocked()
+}
+
+// finishRun records the outcome of a run and releases its context
+func (app *App) finishRun(active *activeRun, err error) Run {
+	run := active.run
+	now := time.Now().UTC()
+	run.FinishedAt = &now
+
+	switch {
+	case err == nil:
+		run.Status = runSucceeded
+	case errors.Is(context.Cause(active.ctx), errRunCancelled), errors.Is(context.Cause(active.ctx), errShutdown):
+		run.Status = runCancelled
+		run.Error = context.Cause(active.ctx).Error()
+	case active.ctx.Err() != nil:
+		run.Status = runFailed
+		run.Error = context.Cause(active.ctx).Error()
+	default:
+		run.Status = runFailed
+		run.Error = err.Error()
+	}
+
+	active.cancel(nil)
+	liveRuns.Delete(run.ID)
+
+	app.runsMu.Lock()
+	delete(app.runs, run.ID)
+	if errors.Is(context.Cause(active.ctx), errShutdown) {
+		// Keep the run recorded as in progress, so the next instance resumes or aborts it
+		interrupted := active.run
+		interrupted.Error = run.Error
+		app.interrupted = append(app.interrupted, interrupted)
+	} else {
+		app.recordRunLocked(run)
+	}
+	app.saveRunsLocked()
+	app.runsMu.Unlock()
+
+	return run
+}
+
+// recordRunLocked adds a finished run to the history. The caller must hold runsMu.
+func (app *App) recordRunLocked(run Run) {
+	app.runHistory = append(app.runHistory, run)
+	if len(app.runHistory) > maxRunHistory {
+		app.runHistory = app.runHistory[len(app.runHistory)-maxRunHistory:]
+	}
+}
+
+// saveRunsLocked writes the runs in progress and the history to the runs file. The caller must hold runsMu.
+func (app *App) saveRunsLocked() {
+	if app.runsFile == "" {
+		return
+	}
+
+	state := runsState{
+		InProgress: append([]Run{}, app.interrupted...),
+		Recent:     app.runHistory,
+	}
+	for _, active := range app.runs {
+		state.InProgress = append(state.InProgress, active.run)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		log.DefaultLogger.Error("Failed to marshal runs", "error", err)
+		return
+	}
+	if err := os.WriteFile(app.runsFile, data, 0644); err != nil {
+		log.DefaultLogger.Error("Failed to write runs file", "error", err)
+	}
+}
+
+// recoverRuns loads the runs file and handles the runs left in progress by the previous instance:
+// they are resumed when the job has resumeInterrupted set, and otherwise recorded as aborted.
+func (app *App) recoverRuns() error {
+	data, err := os.ReadFile(app.runsFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read runs file: %w", err)
+	}
+
+	var state runsState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse runs file: %w", err)
+	}
+
+	var resume []Job
+	var resumeFrom []string
+
+	app.runsMu.Lock()
+	app.runHistory = state.Recent
+	for _, run := range state.InProgress {
+		if _, live := liveRuns.Load(run.ID); live {
+			continue
+		}
+
+		app.mu.RLock()
+		job, exists := app.jobs[run.JobID]
+		app.mu.RUnlock()
+
+		reason := "interrupted by a plugin restart"
+		if run.Error != "" {
+			reason = fmt.Sprintf("interrupted by a plugin restart (%s)", run.Error)
+		}
+
+		now := time.Now().UTC()
+		run.Status = runAborted
+		run.FinishedAt = &now
+		switch {
+		case !exists:
+			run.Error = reason + "; job no longer exists"
+		case job.ResumeInterrupted:
+			run.Error = reason + "; resumed"
+			resume = append(resume, job)
+			resumeFrom = append(resumeFrom, run.ID)
+		default:
+			run.Error = reason
+		}
+		app.recordRunLocked(run)
+		log.DefaultLogger.Warn("Found interrupted run", "run", run.ID, "job", run.JobID, "reason", run.Error)
+	}
+	app.saveRunsLocked()
+	app.runsMu.Unlock()
+
+	for i, job := range resume {
+		active := app.startRun(context.Background(), job, triggerResume)
+
+		app.updateRun(active, func(run *Run) {
+			run.ResumedFrom = resumeFrom[i]
+		})
+
+		log.DefaultLogger.Info("Resuming interrupted run", "job", job.ID, "run", active.run.ID, "resumedFrom", resumeFrom[i])
+		go app.executeRun(active, job)
+	}
+
+	return nil
+}
+
+// waitForRuns waits until no run is in progress, reporting false if ctx is done first
+func (app *App) waitForRuns(ctx context.Context) bool {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		app.runsMu.Lock()
+		idle := len(app.runs) == 0
+		app.runsMu.Unlock()
+		if idle {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// runJob executes a job as a tracked run and waits for it to finish
+func (app *App) runJob(parent context.Context, job Job, trigger string) Run {
+	return app.executeRun(app.startRun(parent, job, trigger), job)
+}
+
+// executeRun executes a job within a started run and records the outcome
+func (app *App) executeRun(active *activeRun, job Job) Run {
+	// Relative time ranges are resolved once, so the report and its dashboard link show the same
+	// period; catch-up runs report on the period of the occurrence they stand in for
+	reportTime := active.run.StartedAt
+	if active.run.ScheduledFor != nil {
+		reportTime = *active.run.ScheduledFor
+	}
+	job, err := app.resolveTimeRange(job, reportTime)
+	if err != nil {
+		err = fmt.Errorf("failed to resolve time range: %w", err)
+	} else {
+		err = app.executeJob(active.ctx, job)
+	}
+	run := app.finishRun(active, err)
+	if err != nil {
+		log.DefaultLogger.Error("Failed to execute job", "id", job.ID, "run", run.ID, "status", run.Status, "error", run.Error)
+	}
+	return run
+}
+
+// cancelRun cancels a run in progress with the given cause, reporting whether it was found
+func (app *App) cancelRun(runID string, cause error) bool {
+	app.runsMu.Lock()
+	active, ok := app.runs[runID]
+	app.runsMu.Unlock()
+
+	if ok {
+		active.cancel(cause)
+	}
+	return ok
+}
+
+// cancelAllRuns cancels every run in progress with the given cause
+func (app *App) cancelAllRuns(cause error) {
+	app.runsMu.Lock()
+	defer app.runsMu.Unlock()
+
+	for _, active := range app.runs {
+		active.cancel(cause)
+	}
+}
+
+// activeRuns returns the runs in progress, oldest first
+func (app *App) activeRuns() []Run {
+	app.runsMu.Lock()
+	runs := make([]Run, 0, len(app.runs))
+	for _, active := range app.runs {
+		runs = append(runs, active.run)
+	}
+	app.runsMu.Unlock()
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.Before(runs[j].StartedAt)
+	})
+	return runs
+}
+
+// recentRuns returns the finished runs, most recently finished first
+func (app *App) recentRuns() []Run {
+	app.runsMu.Lock()
+	defer app.runsMu.Unlock()
+
+	runs := make([]Run, len(app.runHistory))
+	for i, run := range app.runHistory {
+		runs[len(runs)-1-i] = run
+	}
+	return runs
+}
+
+// handleRuns lists the runs in progress followed by the recently finished runs
+func (app *App) handleRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(append(app.activeRuns(), app.recentRuns()...))
+}
+
+// handleRunByID handles /runs/{id}/cancel, and /runs/{id}/files and /runs/{id}/links for the
+// reports the run archived
+func (app *App) handleRunByID(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/runs/")
+	if runID, ok := strings.CutSuffix(path, "/files"); ok {
+		app.handleRunFiles(w, r, runID)
+		return
+	}
+	if runID, ok := strings.CutSuffix(path, "/links"); ok {
+		app.handleRunLinks(w, r, runID)
+		return
+	}
+	if !strings.HasSuffix(path, "/cancel") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	runID := strings.TrimSuffix(path, "/cancel")
+
+	app.runsMu.Lock()
+	active, ok := app.runs[runID]
+	app.runsMu.Unlock()
+
+	if !ok {
+		http.Error(w, "Run not found or already finished", http.StatusNotFound)
+		return
+	}
+
+	app.mu.RLock()
+	job := app.jobs[active.run.JobID]
+	app.mu.RUnlock()
+
+	if !canManageJob(requestUser(r), job) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	app.cancelRun(runID, errRunCancelled)
+	log.DefaultLogger.Info("Cancelled run", "run", runID, "job", active.run.JobID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Run cancelled",
+	})
+}
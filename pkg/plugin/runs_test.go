@@ -0,0 +1,215 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// newHangingGrafana returns a Grafana stub whose render requests block until the client gives up.
+// The channels receive a value when a request arrives and when it is aborted.
+func newHangingGrafana(t *testing.T) (*httptest.Server, chan struct{}, chan struct{}) {
+	t.Helper()
+	received := make(chan struct{}, 10)
+	aborted := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-r.Context().Done()
+		aborted <- struct{}{}
+	}))
+	t.Cleanup(server.Close)
+	return server, received, aborted
+}
+
+func waitFor(t *testing.T, ch chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for %s", what)
+	}
+}
+
+func waitForNoActiveRuns(t *testing.T, app *App) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(app.activeRuns()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for runs to finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCancelRun(t *testing.T) {
+	grafana, received, aborted := newHangingGrafana(t)
+
+	app := newTestApp(t)
+	app.config.GrafanaURL = grafana.URL
+	app.jobs["daily"] = Job{ID: "daily", Cron: "0 9 * * *", Owner: "editor", Format: "png"}
+
+	editor := &backend.User{Login: "editor", Role: roleEditor}
+	resp := callResource(t, app, editor, http.MethodPost, "/jobs/daily/execute", "")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+
+	var body map[string]string
+	json.Unmarshal(resp.Body, &body)
+	runID := body["runId"]
+	if runID == "" {
+		t.Fatalf("Expected a run ID, got %s", resp.Body)
+	}
+
+	waitFor(t, received, "the render request")
+
+	resp = callResource(t, app, editor, http.MethodGet, "/runs", "")
+	if !strings.Contains(string(resp.Body), runID) {
+		t.Errorf("Expected run %s to be listed, got %s", runID, resp.Body)
+	}
+
+	// Other editors cannot cancel someone else's run
+	resp = callResource(t, app, &backend.User{Login: "other", Role: roleEditor}, http.MethodPost, "/runs/"+runID+"/cancel", "")
+	if resp.Status != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", resp.Status)
+	}
+
+	resp = callResource(t, app, editor, http.MethodPost, "/runs/"+runID+"/cancel", "")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+
+	waitFor(t, aborted, "the render request to be aborted")
+	waitForNoActiveRuns(t, app)
+}
+
+func TestRunTimeout(t *testing.T) {
+	grafana, _, _ := newHangingGrafana(t)
+
+	app := newTestApp(t)
+	app.config.GrafanaURL = grafana.URL
+
+	run := app.runJob(context.Background(), Job{ID: "slow", Format: "png", TimeoutSeconds: 1}, triggerSchedule)
+	if run.Status != runFailed || !strings.Contains(run.Error, "timed out after 1s") {
+		t.Errorf("Expected run to time out, got %+v", run)
+	}
+}
+
+func TestDisposeCancelsRuns(t *testing.T) {
+	grafana, received, _ := newHangingGrafana(t)
+
+	app := newTestApp(t)
+	app.config.GrafanaURL = grafana.URL
+
+	done := make(chan Run)
+	go func() {
+		done <- app.runJob(context.Background(), Job{ID: "daily", Format: "png"}, triggerSchedule)
+	}()
+
+	waitFor(t, received, "the render request")
+	app.Dispose()
+
+	select {
+	case run := <-done:
+		if run.Status != runCancelled || run.Error != errShutdown.Error() {
+			t.Errorf("Expected run to be aborted by shutdown, got %+v", run)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Dispose to abort the run")
+	}
+}
+
+func TestDisposeWaitsForRuns(t *testing.T) {
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("png"))
+	}))
+	defer grafana.Close()
+
+	app := newTestApp(t)
+	app.config.GrafanaURL = grafana.URL
+	app.shutdownGrace = 5 * time.Second
+
+	done := make(chan Run, 1)
+	go func() {
+		done <- app.runJob(context.Background(), Job{ID: "daily", Format: "png"}, triggerSchedule)
+	}()
+	for len(app.activeRuns()) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	app.Dispose()
+
+	select {
+	case run := <-done:
+		// The render finishes; delivery fails because no SMTP server is configured
+		if run.Status == runCancelled {
+			t.Errorf("Expected run to finish within the grace period, got %+v", run)
+		}
+	default:
+		t.Fatal("Expected Dispose to wait for the run")
+	}
+}
+
+func TestRecoverInterruptedRuns(t *testing.T) {
+	grafana, received, _ := newHangingGrafana(t)
+
+	app := newTestApp(t)
+	app.config.GrafanaURL = grafana.URL
+
+	go app.runJob(context.Background(), Job{ID: "daily", Format: "png"}, triggerSchedule)
+	waitFor(t, received, "the render request")
+	app.Dispose()
+
+	var state runsState
+	data, _ := os.ReadFile(app.runsFile)
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("Failed to read runs file: %v", err)
+	}
+	if len(state.InProgress) != 1 || state.InProgress[0].Status != runRunning {
+		t.Fatalf("Expected the aborted run to stay in progress, got %+v", state)
+	}
+	interruptedID := state.InProgress[0].ID
+
+	// A run left behind by a crash, for a job that resumes interrupted runs
+	crashed := Run{ID: "run-crashed", JobID: "weekly", Trigger: triggerSchedule, Status: runRunning, StartedAt: time.Now().UTC()}
+	state.InProgress = append(state.InProgress, crashed)
+	data, _ = json.Marshal(state)
+	os.WriteFile(app.runsFile, data, 0644)
+
+	next := newTestApp(t)
+	next.runsFile = app.runsFile
+	next.config.GrafanaURL = grafana.URL
+	next.jobs["daily"] = Job{ID: "daily", Format: "png"}
+	next.jobs["weekly"] = Job{ID: "weekly", Format: "png", ResumeInterrupted: true}
+
+	if err := next.recoverRuns(); err != nil {
+		t.Fatalf("Failed to recover runs: %v", err)
+	}
+
+	active := next.activeRuns()
+	if len(active) != 1 || active[0].JobID != "weekly" || active[0].Trigger != triggerResume || active[0].ResumedFrom != "run-crashed" {
+		t.Fatalf("Expected the weekly run to be resumed, got %+v", active)
+	}
+
+	recent := map[string]Run{}
+	for _, run := range next.recentRuns() {
+		recent[run.ID] = run
+	}
+	if run := recent[interruptedID]; run.Status != runAborted || !strings.Contains(run.Error, errShutdown.Error()) {
+		t.Errorf("Expected the interrupted run to be aborted with the shutdown reason, got %+v", run)
+	}
+	if run := recent["run-crashed"]; run.Status != runAborted || !strings.Contains(run.Error, "resumed") {
+		t.Errorf("Expected the crashed run to be aborted and resumed, got %+v", run)
+	}
+
+	next.cancelAllRuns(errRunCancelled)
+	waitForNoActiveRuns(t, next)
+}
@@ -0,0 +1,149 @@
+package plugin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/robfig/cron/v3"
+)
+
+// Job lifecycle states reported by listJobs
+const (
+	jobPending  = "pending"  // StartAt is in the future
+	jobActive   = "active"   // the job has occurrences left
+	jobExpired  = "expired"  // EndAt has passed or the one-shot job has fired
+	jobDisabled = "disabled" // disabled by a user
+)
+
+// boundedSchedule restricts a schedule to the occurrences between start and end (inclusive)
+type boundedSchedule struct {
+	schedule cron.Schedule
+	start    *time.Time
+	end      *time.Time
+}
+
+// Next returns the next occurrence within the bounds, or the zero time when there is none,
+// which the scheduler never fires
+func (s boundedSchedule) Next(t time.Time) time.Time {
+	if s.start != nil && t.Before(*s.start) {
+		// Occurrences falling exactly on start are included
+		t = s.start.Add(-time.Second)
+	}
+	next := s.schedule.Next(t)
+	if next.IsZero() || (s.end != nil && next.After(*s.end)) {
+		return time.Time{}
+	}
+	return next
+}
+
+// oneShotSchedule fires once, at a fixed time
+type oneShotSchedule struct {
+	at time.Time
+}
+
+// Next returns the one-shot time if it is after t, or the zero time
+func (s oneShotSchedule) Next(t time.Time) time.Time {
+	if t.Before(s.at) {
+		return s.at
+	}
+	return time.Time{}
+}
+
+// schedule returns the job's schedule: its one-shot RunAt time, or its cron expression bounded by StartAt and EndAt
+func (job Job) schedule() (cron.Schedule, error) {
+	if job.RunAt != nil {
+		return oneShotSchedule{at: *job.RunAt}, nil
+	}
+
+	schedule, err := cron.ParseStandard(job.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression: %w", err)
+	}
+	if job.StartAt == nil && job.EndAt == nil {
+		return schedule, nil
+	}
+	return boundedSchedule{schedule: schedule, start: job.StartAt, end: job.EndAt}, nil
+}
+
+// validateSchedule checks the job's cron expression or one-shot time and its bounds
+func (job Job) validateSchedule() error {
+	if job.RunAt != nil && (job.StartAt != nil || job.EndAt != nil) {
+		return fmt.Errorf("runAt cannot be combined with startAt or endAt")
+	}
+	if job.StartAt != nil && job.EndAt != nil && !job.EndAt.After(*job.StartAt) {
+		return fmt.Errorf("endAt must be after startAt")
+	}
+	_, err := job.schedule()
+	return err
+}
+
+// validateRunAt checks that a newly set one-shot time is not in the past, as the job would expire
+// without running. previous is the one-shot time the job had before, which may have passed once it ran.
+func (job Job) validateRunAt(previous *time.Time, now time.Time) error {
+	if job.RunAt == nil || (previous != nil && previous.Equal(*job.RunAt)) {
+		return nil
+	}
+	if job.RunAt.Before(now) {
+		return fmt.Errorf("runAt %s is in the past", job.RunAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// expired reports whether the job has no occurrence left after now
+func (job Job) expired(now time.Time) bool {
+	schedule, err := job.schedule()
+	if err != nil {
+		return false
+	}
+	return schedule.Next(now).IsZero()
+}
+
+// lifecycleState returns the job's lifecycle state at now
+func (job Job) lifecycleState(now time.Time) string {
+	switch {
+	case job.expired(now):
+		return jobExpired
+	case job.Disabled:
+		return jobDisabled
+	case job.StartAt != nil && now.Before(*job.StartAt):
+		return jobPending
+	default:
+		return jobActive
+	}
+}
+
+// expireJobs disables the enabled jobs that have no occurrence left
+func (app *App) expireJobs(now time.Time) {
+	app.mu.RLock()
+	var expired []string
+	for id, job := range app.jobs {
+		if !job.Disabled && job.expired(now) {
+			expired = append(expired, id)
+		}
+	}
+	app.mu.RUnlock()
+
+	for _, id := range expired {
+		app.expireJob(id, now)
+	}
+}
+
+// expireJob disables the job if it has no occurrence left, removing it from the scheduler
+func (app *App) expireJob(jobID string, now time.Time) {
+	app.mu.Lock()
+	job, ok := app.jobs[jobID]
+	if !ok || job.Disabled || !job.expired(now) {
+		app.mu.Unlock()
+		return
+	}
+	job.Disabled = true
+	app.jobs[jobID] = job
+	app.mu.Unlock()
+
+	app.unscheduleJob(jobID)
+	if err := app.saveJobs(); err != nil {
+		log.DefaultLogger.Error("Failed to save jobs", "error", err)
+	}
+	log.DefaultLogger.Info("Disabled expired job", "id", jobID)
+}
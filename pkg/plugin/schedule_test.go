@@ -0,0 +1,200 @@
+package plugin
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/robfig/cron/v3"
+)
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestBoundedSchedule(t *testing.T) {
+	mondays, _ := cron.ParseStandard("0 9 * * 1")
+	start := time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC) // a Monday
+	end := time.Date(2024, 9, 30, 23, 59, 0, 0, time.UTC)
+	schedule := boundedSchedule{schedule: mondays, start: &start, end: &end}
+
+	if next := schedule.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !next.Equal(start) {
+		t.Errorf("Expected first occurrence on start, got %v", next)
+	}
+	if next := schedule.Next(time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)); !next.Equal(time.Date(2024, 8, 5, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected an occurrence within the window, got %v", next)
+	}
+	if next := schedule.Next(time.Date(2024, 9, 30, 9, 0, 0, 0, time.UTC)); !next.IsZero() {
+		t.Errorf("Expected no occurrence after end, got %v", next)
+	}
+}
+
+func TestOneShotSchedule(t *testing.T) {
+	at := time.Date(2024, 10, 1, 8, 0, 0, 0, time.UTC)
+	schedule := oneShotSchedule{at: at}
+
+	if next := schedule.Next(at.Add(-time.Hour)); !next.Equal(at) {
+		t.Errorf("Expected the one-shot time, got %v", next)
+	}
+	if next := schedule.Next(at); !next.IsZero() {
+		t.Errorf("Expected no occurrence after the one-shot time, got %v", next)
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	start := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		job     Job
+		wantErr bool
+	}{
+		{"cron", Job{Cron: "0 9 * * *"}, false},
+		{"bounded cron", Job{Cron: "0 9 * * 1", StartAt: &start, EndAt: &end}, false},
+		{"one-shot without cron", Job{RunAt: &start}, false},
+		{"invalid cron", Job{Cron: "every day"}, true},
+		{"end before start", Job{Cron: "0 9 * * *", StartAt: &end, EndAt: &start}, true},
+		{"one-shot with bounds", Job{RunAt: &start, EndAt: &end}, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.job.validateSchedule(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateSchedule() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateRunAt(t *testing.T) {
+	now := time.Date(2024, 8, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		job      Job
+		previous *time.Time
+		wantErr  bool
+	}{
+		{"cron", Job{Cron: "0 9 * * *"}, nil, false},
+		{"one-shot to come", Job{RunAt: timePtr(now.Add(time.Hour))}, nil, false},
+		{"one-shot in the past", Job{RunAt: &past}, nil, true},
+		{"one-shot moved to the past", Job{RunAt: &past}, timePtr(now.Add(time.Hour)), true},
+		{"one-shot that already ran", Job{RunAt: &past}, timePtr(past), false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.job.validateRunAt(tt.previous, now); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateRunAt() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLifecycleState(t *testing.T) {
+	now := time.Date(2024, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		job  Job
+		want string
+	}{
+		{"active", Job{Cron: "0 9 * * *"}, jobActive},
+		{"pending", Job{Cron: "0 9 * * *", StartAt: timePtr(now.Add(24 * time.Hour))}, jobPending},
+		{"ended", Job{Cron: "0 9 * * *", EndAt: timePtr(now.Add(-time.Hour))}, jobExpired},
+		{"one-shot to come", Job{RunAt: timePtr(now.Add(time.Hour))}, jobActive},
+		{"one-shot fired", Job{RunAt: timePtr(now.Add(-time.Hour)), Disabled: true}, jobExpired},
+		{"disabled", Job{Cron: "0 9 * * *", Disabled: true}, jobDisabled},
+	}
+
+	for _, tt := range tests {
+		if got := tt.job.lifecycleState(now); got != tt.want {
+			t.Errorf("%s: lifecycleState() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExpireJobs(t *testing.T) {
+	app := newTestApp(t)
+	now := time.Now()
+	for _, job := range []Job{
+		{ID: "ended", Cron: "0 9 * * *", EndAt: timePtr(now.Add(-time.Hour))},
+		{ID: "running", Cron: "0 9 * * *", EndAt: timePtr(now.Add(30 * 24 * time.Hour))},
+	} {
+		app.jobs[job.ID] = job
+		if err := app.scheduleJob(job); err != nil {
+			t.Fatalf("Failed to schedule job: %v", err)
+		}
+	}
+
+	app.expireJobs(now)
+
+	if !app.jobs["ended"].Disabled || app.jobs["running"].Disabled {
+		t.Errorf("Expected only the ended job to be disabled, got %+v", app.jobs)
+	}
+	if _, scheduled := app.cronIDs["ended"]; scheduled {
+		t.Error("Expected the ended job to be removed from the scheduler")
+	}
+	if _, scheduled := app.cronIDs["running"]; !scheduled {
+		t.Error("Expected the running job to stay scheduled")
+	}
+}
+
+func TestOneShotJobFiresOnce(t *testing.T) {
+	app := newTestApp(t)
+	app.scheduler.Start()
+	editor := &backend.User{Login: "editor", Role: roleEditor}
+
+	runAt := time.Now().Add(2 * time.Second).UTC().Format(time.RFC3339)
+	resp := callResource(t, app, editor, http.MethodPost, "/jobs", `{"id": "once", "runAt": "`+runAt+`", "format": "png"}`)
+	if resp.Status != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", resp.Status, resp.Body)
+	}
+
+	resp = callResource(t, app, editor, http.MethodGet, "/jobs", "")
+	if !strings.Contains(string(resp.Body), `"state":"active"`) {
+		t.Errorf("Expected one-shot job to be active, got %s", resp.Body)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		app.mu.RLock()
+		disabled := app.jobs["once"].Disabled
+		app.mu.RUnlock()
+		if disabled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the one-shot job to be disabled after firing")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if runs := app.recentRuns(); len(runs) != 1 || runs[0].JobID != "once" {
+		t.Errorf("Expected exactly one run, got %+v", runs)
+	}
+	resp = callResource(t, app, editor, http.MethodGet, "/jobs", "")
+	if !strings.Contains(string(resp.Body), `"state":"expired"`) {
+		t.Errorf("Expected one-shot job to be expired, got %s", resp.Body)
+	}
+
+	// The job can still be edited once its one-shot time has passed
+	resp = callResource(t, app, editor, http.MethodPut, "/jobs/once", `{"runAt": "`+runAt+`", "format": "pdf", "disabled": true}`)
+	if resp.Status != http.StatusOK {
+		t.Errorf("Expected status 200 when keeping a past one-shot time, got %d: %s", resp.Status, resp.Body)
+	}
+}
+
+func TestOneShotJobInThePastRejected(t *testing.T) {
+	app := newTestApp(t)
+	editor := &backend.User{Login: "editor", Role: roleEditor}
+
+	runAt := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	resp := callResource(t, app, editor, http.MethodPost, "/jobs", `{"id": "late", "runAt": "`+runAt+`", "format": "png"}`)
+	if resp.Status != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", resp.Status, resp.Body)
+	}
+	if _, exists := app.jobs["late"]; exists {
+		t.Error("Expected the job not to be created")
+	}
+}
@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// encryptedPrefix marks secret values encrypted with the plugin secret key
+	encryptedPrefix = "enc:v1:"
+
+	// secretPlaceholder is returned instead of configured secrets; posting it back keeps the stored value
+	secretPlaceholder = "********"
+)
+
+// secretKey returns the key used to encrypt secrets at rest. It comes from REPORTER_SECRET_KEY,
+// from the file named by REPORTER_SECRET_KEY_FILE, or from a key file generated in the data directory.
+func (app *App) secretKey() ([]byte, error) {
+	app.secretKeyMu.Lock()
+	defer app.secretKeyMu.Unlock()
+
+	if app.key != nil {
+		return app.key, nil
+	}
+
+	if value := os.Getenv("REPORTER_SECRET_KEY"); value != "" {
+		key := sha256.Sum256([]byte(value))
+		app.key = key[:]
+		return app.key, nil
+	}
+
+	keyFile := os.Getenv("REPORTER_SECRET_KEY_FILE")
+	if keyFile == "" {
+		keyFile = filepath.Join(app.dataDir, "secret.key")
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if os.IsNotExist(err) {
+		// First start: generate a random key next to the data it protects
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate secret key: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(keyFile), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create secret key directory: %w", err)
+		}
+		data = []byte(hex.EncodeToString(raw))
+		if err := os.WriteFile(keyFile, data, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write secret key file: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read secret key file: %w", err)
+	}
+
+	key := sha256.Sum256([]byte(strings.TrimSpace(string(data))))
+	app.key = key[:]
+	return app.key, nil
+}
+
+// encryptSecret encrypts a value with AES-256-GCM. Empty values stay empty.
+func encryptSecret(key []byte, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret decrypts a value produced by encryptSecret. Values without the prefix are returned as-is.
+func decryptSecret(key []byte, value string) (string, error) {
+	if !isEncrypted(value) {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted secret is too short")
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret (wrong key?): %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// secretStatus returns the placeholder for configured secrets, never the secret itself
+func secretStatus(s string) string {
+	if s == "" {
+		return ""
+	}
+	return secretPlaceholder
+}
@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecryptSecret(t *testing.T) {
+	app := newTestApp(t)
+	key, err := app.secretKey()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	encrypted, err := encryptSecret(key, "s3cret")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(encrypted, encryptedPrefix) || strings.Contains(encrypted, "s3cret") {
+		t.Errorf("Expected encrypted value, got %s", encrypted)
+	}
+
+	decrypted, err := decryptSecret(key, encrypted)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if decrypted != "s3cret" {
+		t.Errorf("Expected s3cret, got %s", decrypted)
+	}
+
+	otherKey := make([]byte, 32)
+	if _, err := decryptSecret(otherKey, encrypted); err == nil {
+		t.Error("Expected error when decrypting with the wrong key")
+	}
+}
+
+func TestLoadConfigMigratesPlaintextSecrets(t *testing.T) {
+	app := newTestApp(t)
+	plaintext := `{"grafanaUrl": "http://grafana:3000", "grafanaApiKey": "glsa_key", "smtpPassword": "smtp-pass"}`
+	if err := os.WriteFile(app.configFile, []byte(plaintext), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.loadConfig(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if app.config.GrafanaAPIKey != "glsa_key" || app.config.SMTPPassword != "smtp-pass" {
+		t.Errorf("Expected secrets to be loaded, got %+v", app.config)
+	}
+
+	data, err := os.ReadFile(app.configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "glsa_key") || strings.Contains(string(data), "smtp-pass") {
+		t.Errorf("Expected config file to no longer contain plaintext secrets: %s", data)
+	}
+
+	// A fresh instance with the same key file reads the migrated file
+	reloaded := newTestApp(t)
+	reloaded.dataDir = app.dataDir
+	reloaded.configFile = app.configFile
+	if err := reloaded.loadConfig(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reloaded.config.GrafanaAPIKey != "glsa_key" || reloaded.config.SMTPPassword != "smtp-pass" {
+		t.Errorf("Expected decrypted secrets, got %+v", reloaded.config)
+	}
+}
+
+func TestSecureJSONSecretsAreNotStored(t *testing.T) {
+	app := newTestApp(t)
+	app.secureSMTPPassword = "from-grafana"
+	app.config.SMTPPassword = "from-grafana"
+
+	if err := app.saveConfig(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var stored Config
+	data, _ := os.ReadFile(app.configFile)
+	json.Unmarshal(data, &stored)
+	if stored.SMTPPassword != "" {
+		t.Errorf("Expected secureJsonData secret not to be written, got %s", stored.SMTPPassword)
+	}
+}
+
+func TestGetConfigNeverReturnsSecrets(t *testing.T) {
+	app := newTestApp(t)
+	app.config = Config{GrafanaAPIKey: "glsa_key", SMTPPassword: "smtp-pass"}
+
+	rec := httptest.NewRecorder()
+	app.handleConfig(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+	body := rec.Body.String()
+	if strings.Contains(body, "glsa") || strings.Contains(body, "smtp-pass") {
+		t.Errorf("Expected no secret material in response, got %s", body)
+	}
+
+	// Posting the placeholder back keeps the stored secret
+	rec = httptest.NewRecorder()
+	app.handleConfig(rec, httptest.NewRequest(http.MethodPost, "/config", strings.NewReader(`{"grafanaApiKey": "********", "smtpPassword": "new*pass"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if app.config.GrafanaAPIKey != "glsa_key" {
+		t.Errorf("Expected API key to be kept, got %s", app.config.GrafanaAPIKey)
+	}
+	if app.config.SMTPPassword != "new*pass" {
+		t.Errorf("Expected password containing an asterisk to be saved, got %s", app.config.SMTPPassword)
+	}
+}
@@ -0,0 +1,127 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// deliveryRecipients returns the job's recipients followed by its subscribers, without duplicates
+func (job Job) deliveryRecipients() []string {
+	seen := make(map[string]bool, len(job.Recipients)+len(job.Subscribers))
+	recipients := make([]string, 0, len(job.Recipients)+len(job.Subscribers))
+	for _, list := range [][]string{job.Recipients, job.Subscribers} {
+		for _, email := range list {
+			key := strings.ToLower(strings.TrimSpace(email))
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			recipients = append(recipients, strings.TrimSpace(email))
+		}
+	}
+	return recipients
+}
+
+// handleSubscribers lets any user subscribe or unsubscribe their own email address to a job
+//
+//   - GET returns whether the current user is subscribed (and the full list for users who manage the job)
+//   - POST subscribes the current user
+//   - DELETE unsubscribes the current user
+func (app *App) handleSubscribers(w http.ResponseWriter, r *http.Request, jobID string) {
+	user := requestUser(r)
+	if user == nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	email := strings.TrimSpace(user.Email)
+
+	app.mu.Lock()
+	job, exists := app.jobs[jobID]
+	if !exists {
+		app.mu.Unlock()
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	subscribed := containsEmail(job.Subscribers, email)
+	changed := false
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if email == "" {
+			app.mu.Unlock()
+			http.Error(w, "Your Grafana user has no email address", http.StatusBadRequest)
+			return
+		}
+		if !subscribed {
+			job.Subscribers = append(job.Subscribers, email)
+			subscribed, changed = true, true
+		}
+	case http.MethodDelete:
+		if subscribed {
+			job.Subscribers = removeEmail(job.Subscribers, email)
+			subscribed, changed = false, true
+		}
+	default:
+		app.mu.Unlock()
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if changed {
+		app.jobs[jobID] = job
+	}
+	app.mu.Unlock()
+
+	if changed {
+		// Scheduled runs read the stored job when they fire, so the job does not need to be rescheduled
+		if err := app.saveJobs(); err != nil {
+			log.DefaultLogger.Error("Failed to save jobs", "error", err)
+		}
+		log.DefaultLogger.Info("Updated job subscription", "id", jobID, "user", user.Login, "subscribed", subscribed)
+	}
+
+	response := map[string]interface{}{
+		"subscribed": subscribed,
+	}
+	if canManageJob(user, job) {
+		response["subscribers"] = job.Subscribers
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// visibleTo returns the job as shown to the user. As on /jobs/{id}/subscribers, the subscriber
+// list is only shown to users who manage the job.
+func (job Job) visibleTo(user *backend.User) Job {
+	if !canManageJob(user, job) {
+		job.Subscribers = nil
+	}
+	return job
+}
+
+func containsEmail(list []string, email string) bool {
+	for _, item := range list {
+		if email != "" && strings.EqualFold(item, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func removeEmail(list []string, email string) []string {
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		if !strings.EqualFold(item, email) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
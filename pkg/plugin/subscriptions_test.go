@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"encoding/json"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestSubscribeAndUnsubscribe(t *testing.T) {
+	app := newTestApp(t)
+	app.jobs["daily"] = Job{ID: "daily", Cron: "0 9 * * *", Owner: "editor", Recipients: []string{"team@example.com"}}
+
+	viewer := &backend.User{Login: "viewer", Email: "Viewer@example.com", Role: roleViewer}
+
+	resp := callResource(t, app, viewer, http.MethodPost, "/jobs/daily/subscribers", "")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+
+	var body map[string]interface{}
+	json.Unmarshal(resp.Body, &body)
+	if body["subscribed"] != true {
+		t.Errorf("Expected subscribed=true, got %v", body)
+	}
+	if _, ok := body["subscribers"]; ok {
+		t.Error("Expected viewers not to see the subscriber list")
+	}
+
+	// Subscribing twice (with different case) does not duplicate the address
+	callResource(t, app, viewer, http.MethodPost, "/jobs/daily/subscribers", "")
+	recipients := app.jobs["daily"].deliveryRecipients()
+	if len(recipients) != 2 || recipients[1] != "Viewer@example.com" {
+		t.Errorf("Expected recipients plus one subscriber, got %v", recipients)
+	}
+
+	resp = callResource(t, app, viewer, http.MethodDelete, "/jobs/daily/subscribers", "")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+	if len(app.jobs["daily"].Subscribers) != 0 {
+		t.Errorf("Expected no subscribers, got %v", app.jobs["daily"].Subscribers)
+	}
+}
+
+func TestSubscribeRequiresEmail(t *testing.T) {
+	app := newTestApp(t)
+	app.jobs["daily"] = Job{ID: "daily", Cron: "0 9 * * *"}
+
+	resp := callResource(t, app, &backend.User{Login: "noemail", Role: roleViewer}, http.MethodPost, "/jobs/daily/subscribers", "")
+	if resp.Status != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", resp.Status)
+	}
+}
+
+func TestUpdateJobKeepsSubscribers(t *testing.T) {
+	app := newTestApp(t)
+	app.jobs["daily"] = Job{ID: "daily", Cron: "0 9 * * *", Owner: "editor", Subscribers: []string{"viewer@example.com"}}
+
+	editor := &backend.User{Login: "editor", Role: roleEditor}
+	resp := callResource(t, app, editor, http.MethodPut, "/jobs/daily", `{"cron": "0 10 * * *", "subscribers": []}`)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", resp.Status, resp.Body)
+	}
+	if got := app.jobs["daily"].Subscribers; len(got) != 1 {
+		t.Errorf("Expected subscribers to be kept, got %v", got)
+	}
+}
+
+func TestJobSubscribersHiddenFromViewers(t *testing.T) {
+	app := newTestApp(t)
+	app.jobs["daily"] = Job{ID: "daily", Cron: "0 9 * * *", Owner: "editor", Subscribers: []string{"viewer@example.com"}}
+
+	viewer := &backend.User{Login: "viewer", Email: "viewer@example.com", Role: roleViewer}
+	for _, path := range []string{"/jobs", "/jobs/daily"} {
+		resp := callResource(t, app, viewer, http.MethodGet, path, "")
+		if resp.Status != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", path, resp.Status, resp.Body)
+		}
+		if strings.Contains(string(resp.Body), "viewer@example.com") {
+			t.Errorf("%s: expected viewers not to see the subscriber list, got %s", path, resp.Body)
+		}
+	}
+
+	// The job's owner still sees who subscribed
+	editor := &backend.User{Login: "editor", Role: roleEditor}
+	for _, path := range []string{"/jobs", "/jobs/daily"} {
+		resp := callResource(t, app, editor, http.MethodGet, path, "")
+		if !strings.Contains(string(resp.Body), "viewer@example.com") {
+			t.Errorf("%s: expected the owner to see the subscriber list, got %s", path, resp.Body)
+		}
+	}
+}
+
+func TestScheduledRunsUseCurrentSubscribers(t *testing.T) {
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testPNG(t, 10, 10, color.White))
+	}))
+	defer grafana.Close()
+	smtpServer := startFakeSMTP(t)
+	port, _ := strconv.Atoi(smtpServer.port)
+
+	app := newTestApp(t)
+	app.config = Config{GrafanaURL: grafana.URL, SMTPHost: smtpServer.host, SMTPPort: port, SMTPFrom: "reports@example.com"}
+	job := Job{ID: "daily", Cron: "0 9 * * *", DashboardUID: "abc", Format: "png", Owner: "editor", Recipients: []string{"team@example.com"}}
+	app.jobs[job.ID] = job
+	if err := app.scheduleJob(job); err != nil {
+		t.Fatal(err)
+	}
+	fire := func() string {
+		t.Helper()
+		app.scheduler.Entry(app.cronIDs[job.ID]).Job.Run()
+		messages := smtpServer.received()
+		if len(messages) == 0 {
+			t.Fatal("Expected the scheduled run to send an email")
+		}
+		return messages[len(messages)-1]
+	}
+
+	viewer := &backend.User{Login: "viewer", Email: "viewer@example.com", Role: roleViewer}
+	callResource(t, app, viewer, http.MethodPost, "/jobs/daily/subscribers", "")
+	if message := fire(); !strings.Contains(message, "viewer@example.com") {
+		t.Errorf("Expected the new subscriber to receive the report, got %s", message)
+	}
+
+	callResource(t, app, viewer, http.MethodDelete, "/jobs/daily/subscribers", "")
+	if message := fire(); strings.Contains(message, "viewer@example.com") {
+		t.Errorf("Expected the unsubscribed user not to receive the report, got %s", message)
+	}
+}
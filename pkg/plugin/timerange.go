@@ -0,0 +1,239 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeRangePresets are the calendar-aligned ranges a job can select with TimeRange, as Grafana date math
+var timeRangePresets = map[string][2]string{
+	"today":                 {"now/d", "now/d"},
+	"previousDay":           {"now-1d/d", "now-1d/d"},
+	"weekToDate":            {"now/w", "now"},
+	"previousWeek":          {"now-1w/w", "now-1w/w"},
+	"monthToDate":           {"now/M", "now"},
+	"previousMonth":         {"now-1M/M", "now-1M/M"},
+	"quarterToDate":         {"now/Q", "now"},
+	"previousQuarter":       {"now-1Q/Q", "now-1Q/Q"},
+	"yearToDate":            {"now/y", "now"},
+	"previousYear":          {"now-1y/y", "now-1y/y"},
+	"fiscalQuarterToDate":   {"now/fQ", "now"},
+	"previousFiscalQuarter": {"now-1Q/fQ", "now-1Q/fQ"},
+	"fiscalYearToDate":      {"now/fy", "now"},
+	"previousFiscalYear":    {"now-1y/fy", "now-1y/fy"},
+}
+
+// timeRangeResolver converts relative time expressions to absolute times
+type timeRangeResolver struct {
+	now         time.Time // in the time zone periods are aligned to
+	fiscalStart int       // first month of the fiscal year, 1 to 12
+}
+
+// validateTimeRange checks the job's time range preset, time zone and relative from/to expressions
+func (job Job) validateTimeRange() error {
+	if job.TimeRange != "" {
+		if _, ok := timeRangePresets[job.TimeRange]; !ok {
+			names := make([]string, 0, len(timeRangePresets))
+			for name := range timeRangePresets {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("invalid time range %q (expected one of %s)", job.TimeRange, strings.Join(names, ", "))
+		}
+	}
+	if _, err := job.location(); err != nil {
+		return err
+	}
+
+	resolver := timeRangeResolver{now: time.Now(), fiscalStart: 1}
+	for _, expr := range []string{job.From, job.To} {
+		if _, _, err := resolver.resolve(expr, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// location returns the job's time zone, defaulting to UTC like the rendered report
+func (job Job) location() (*time.Location, error) {
+	if job.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(job.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", job.Timezone, err)
+	}
+	return loc, nil
+}
+
+// resolveTimeRange returns the job with its time range converted to absolute epoch-ms from/to,
+// computed at the given time in the job's time zone. Values that are not relative expressions,
+// such as epoch milliseconds or dates, are left unchanged.
+func (app *App) resolveTimeRange(job Job, at time.Time) (Job, error) {
+	loc, err := job.location()
+	if err != nil {
+		return job, err
+	}
+
+	app.configMu.RLock()
+	fiscalStart := app.config.FiscalYearStartMonth
+	app.configMu.RUnlock()
+	if fiscalStart < 1 || fiscalStart > 12 {
+		fiscalStart = 1
+	}
+
+	from, to := job.From, job.To
+	if preset, ok := timeRangePresets[job.TimeRange]; ok {
+		from, to = preset[0], preset[1]
+	}
+
+	resolver := timeRangeResolver{now: at.In(loc), fiscalStart: fiscalStart}
+	if job.From, err = resolver.resolveMillis(from, false); err != nil {
+		return job, err
+	}
+	if job.To, err = resolver.resolveMillis(to, true); err != nil {
+		return job, err
+	}
+	job.TimeRange = ""
+	return job, nil
+}
+
+// resolveMillis resolves a relative expression to epoch milliseconds, leaving other values unchanged
+func (r timeRangeResolver) resolveMillis(expr string, roundUp bool) (string, error) {
+	t, relative, err := r.resolve(expr, roundUp)
+	if err != nil || !relative {
+		return expr, err
+	}
+	return strconv.FormatInt(t.UnixMilli(), 10), nil
+}
+
+// resolve evaluates Grafana date math such as "now-1M/M": "now", followed by any number of
+// offsets (+ or -, an optional count and a unit) and roundings to the start of a unit (/ and
+// a unit), or to its last millisecond with roundUp. Units are s, m, h, d, w (weeks start on
+// Monday), M, Q and y, and for rounding also fQ and fy for fiscal quarters and years.
+// It reports false for expressions that do not start with "now".
+func (r timeRangeResolver) resolve(expr string, roundUp bool) (time.Time, bool, error) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "now") {
+		return time.Time{}, false, nil
+	}
+
+	t := r.now
+	rest := expr[len("now"):]
+	for rest != "" {
+		op := rest[0]
+		if op != '+' && op != '-' && op != '/' {
+			return time.Time{}, true, fmt.Errorf("invalid time expression %q", expr)
+		}
+		rest = rest[1:]
+
+		count := 1
+		if op != '/' {
+			digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+			if digits > 0 {
+				count, _ = strconv.Atoi(rest[:digits])
+			}
+			rest = rest[digits:]
+		}
+
+		unit := rest
+		if i := strings.IndexAny(rest, "+-/"); i >= 0 {
+			unit = rest[:i]
+		}
+		rest = rest[len(unit):]
+		if !isTimeUnit(unit) || (op != '/' && strings.HasPrefix(unit, "f")) {
+			return time.Time{}, true, fmt.Errorf("invalid unit %q in time expression %q", unit, expr)
+		}
+
+		switch op {
+		case '/':
+			t = r.startOf(t, unit)
+			if roundUp {
+				t = addTimeUnit(t, strings.TrimPrefix(unit, "f"), 1).Add(-time.Millisecond)
+			}
+		case '-':
+			t = addTimeUnit(t, unit, -count)
+		default:
+			t = addTimeUnit(t, unit, count)
+		}
+	}
+	return t, true, nil
+}
+
+// isTimeUnit reports whether unit is a unit of date math
+func isTimeUnit(unit string) bool {
+	switch unit {
+	case "s", "m", "h", "d", "w", "M", "Q", "y", "fQ", "fy":
+		return true
+	}
+	return false
+}
+
+// addTimeUnit adds count units to t. Months, quarters and years keep the day of the month,
+// clamped to the length of the target month, so that one month before March 31 is in February.
+func addTimeUnit(t time.Time, unit string, count int) time.Time {
+	switch unit {
+	case "s":
+		return t.Add(time.Duration(count) * time.Second)
+	case "m":
+		return t.Add(time.Duration(count) * time.Minute)
+	case "h":
+		return t.Add(time.Duration(count) * time.Hour)
+	case "d":
+		return t.AddDate(0, 0, count)
+	case "w":
+		return t.AddDate(0, 0, 7*count)
+	case "M":
+		return addMonths(t, count)
+	case "Q":
+		return addMonths(t, 3*count)
+	default:
+		return addMonths(t, 12*count)
+	}
+}
+
+// addMonths adds months to t, clamping the day to the last day of the target month
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	first = first.AddDate(0, months, 0)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// startOf returns the start of the unit containing t
+func (r timeRangeResolver) startOf(t time.Time, unit string) time.Time {
+	loc := t.Location()
+	switch unit {
+	case "s":
+		return t.Truncate(time.Second)
+	case "m":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+	case "h":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case "d":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	case "w":
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, loc)
+	case "M":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	case "Q":
+		return time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, loc)
+	case "fQ", "fy":
+		// Months elapsed since the start of the fiscal year
+		elapsed := (int(t.Month()) - r.fiscalStart + 12) % 12
+		if unit == "fQ" {
+			elapsed %= 3
+		}
+		return time.Date(t.Year(), t.Month()-time.Month(elapsed), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, loc)
+	}
+}
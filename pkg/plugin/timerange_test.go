@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestResolveTimeExpression(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("Time zone database not available")
+	}
+	// Monday, the day after the switch to summer time
+	now := time.Date(2025, 3, 31, 14, 30, 0, 0, paris)
+	endOf := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day+1, 0, 0, 0, 0, paris).Add(-time.Millisecond)
+	}
+
+	tests := []struct {
+		expr        string
+		roundUp     bool
+		fiscalStart int
+		want        time.Time
+	}{
+		{expr: "now", want: now},
+		{expr: "now-24h", want: now.Add(-24 * time.Hour)},
+		{expr: "now-1d/d", want: time.Date(2025, 3, 30, 0, 0, 0, 0, paris)},
+		{expr: "now-1d/d", roundUp: true, want: endOf(2025, 3, 30)},
+		{expr: "now-1w/w", want: time.Date(2025, 3, 24, 0, 0, 0, 0, paris)},
+		{expr: "now-1w/w", roundUp: true, want: endOf(2025, 3, 30)},
+		{expr: "now-1M/M", want: time.Date(2025, 2, 1, 0, 0, 0, 0, paris)},
+		{expr: "now-1M/M", roundUp: true, want: endOf(2025, 2, 28)},
+		{expr: "now/M", want: time.Date(2025, 3, 1, 0, 0, 0, 0, paris)},
+		{expr: "now-1Q/Q", want: time.Date(2024, 10, 1, 0, 0, 0, 0, paris)},
+		{expr: "now-1Q/Q", roundUp: true, want: endOf(2024, 12, 31)},
+		{expr: "now-1y/y", roundUp: true, want: endOf(2024, 12, 31)},
+		{expr: "now/d+8h", want: time.Date(2025, 3, 31, 8, 0, 0, 0, paris)},
+		{expr: "now/fy", fiscalStart: 4, want: time.Date(2024, 4, 1, 0, 0, 0, 0, paris)},
+		{expr: "now-1y/fy", fiscalStart: 4, want: time.Date(2023, 4, 1, 0, 0, 0, 0, paris)},
+		{expr: "now-1y/fy", fiscalStart: 4, roundUp: true, want: endOf(2024, 3, 31)},
+		{expr: "now/fQ", fiscalStart: 2, want: time.Date(2025, 2, 1, 0, 0, 0, 0, paris)},
+		{expr: "now-1Q/fQ", fiscalStart: 2, want: time.Date(2024, 11, 1, 0, 0, 0, 0, paris)},
+		{expr: "now-1Q/fQ", fiscalStart: 2, roundUp: true, want: endOf(2025, 1, 31)},
+	}
+
+	for _, tt := range tests {
+		fiscalStart := tt.fiscalStart
+		if fiscalStart == 0 {
+			fiscalStart = 1
+		}
+		resolver := timeRangeResolver{now: now, fiscalStart: fiscalStart}
+		got, relative, err := resolver.resolve(tt.expr, tt.roundUp)
+		if err != nil || !relative {
+			t.Errorf("resolve(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("resolve(%q, roundUp=%v, fiscalStart=%d) = %v, want %v", tt.expr, tt.roundUp, tt.fiscalStart, got, tt.want)
+		}
+	}
+
+	resolver := timeRangeResolver{now: now, fiscalStart: 1}
+	for _, expr := range []string{"", "1700000000000", "2025-01-01 00:00:00"} {
+		if _, relative, err := resolver.resolve(expr, false); relative || err != nil {
+			t.Errorf("Expected %q to be left unchanged, got relative=%v, err=%v", expr, relative, err)
+		}
+	}
+	for _, expr := range []string{"now-1x", "now/fx", "now-fy", "now*2"} {
+		if _, _, err := resolver.resolve(expr, false); err == nil {
+			t.Errorf("Expected error for %q", expr)
+		}
+	}
+}
+
+func TestValidateTimeRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     Job
+		wantErr bool
+	}{
+		{name: "raw range", job: Job{From: "now-24h", To: "now"}},
+		{name: "absolute range", job: Job{From: "1700000000000", To: "1700003600000"}},
+		{name: "preset", job: Job{TimeRange: "previousMonth", Timezone: "UTC"}},
+		{name: "unknown preset", job: Job{TimeRange: "lastMonth"}, wantErr: true},
+		{name: "unknown time zone", job: Job{From: "now-1d", To: "now", Timezone: "Mars/Olympus"}, wantErr: true},
+		{name: "invalid expression", job: Job{From: "now-1 day", To: "now"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.job.validateTimeRange(); (err != nil) != tt.wantErr {
+				t.Errorf("validateTimeRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunUsesResolvedTimeRange(t *testing.T) {
+	rendered := make(chan url.Values, 1)
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rendered <- r.URL.Query()
+		w.Write([]byte("fake png"))
+	}))
+	defer grafana.Close()
+
+	app := newTestApp(t)
+	app.config.GrafanaURL = grafana.URL
+	app.config.FiscalYearStartMonth = 7
+
+	// A catch-up run reports on the period of the occurrence it stands in for
+	job := Job{ID: "fiscal", DashboardUID: "abc", Format: "png", TimeRange: "previousFiscalYear"}
+	occurrence := time.Date(2025, 7, 1, 6, 0, 0, 0, time.UTC)
+	app.runOccurrence(job, occurrence, triggerCatchUp)
+
+	query := <-rendered
+	wantFrom := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	wantTo := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC).UnixMilli() - 1
+	if query.Get("from") != strconv.FormatInt(wantFrom, 10) || query.Get("to") != strconv.FormatInt(wantTo, 10) {
+		t.Errorf("Expected range %d-%d, got %s-%s", wantFrom, wantTo, query.Get("from"), query.Get("to"))
+	}
+	if query.Get("tz") != "UTC" {
+		t.Errorf("Expected the job's time zone, got %q", query.Get("tz"))
+	}
+
+	// The dashboard link shows the same period as the snapshot
+	resolved, err := app.resolveTimeRange(job, occurrence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, _ := url.Parse(app.buildDashboardURL(grafana.URL, resolved))
+	if link.Query().Get("from") != query.Get("from") || link.Query().Get("to") != query.Get("to") {
+		t.Errorf("Expected dashboard link to match the report, got %s", link)
+	}
+}
+
+func TestDefaultTimezoneIsUTC(t *testing.T) {
+	app := newTestApp(t)
+	job := Job{ID: "daily", DashboardUID: "abc", Format: "png", TimeRange: "previousDay"}
+
+	loc, err := job.location()
+	if err != nil || loc != time.UTC {
+		t.Fatalf("Expected UTC by default, got %v, %v", loc, err)
+	}
+
+	// The period is computed in UTC whatever the zone of the time it is resolved at
+	at := time.Date(2025, 3, 10, 1, 30, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	resolved, err := app.resolveTimeRange(job, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFrom := time.Date(2025, 3, 8, 0, 0, 0, 0, time.UTC).UnixMilli()
+	wantTo := time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC).UnixMilli() - 1
+	if resolved.From != strconv.FormatInt(wantFrom, 10) || resolved.To != strconv.FormatInt(wantTo, 10) {
+		t.Errorf("Expected range %d-%d, got %s-%s", wantFrom, wantTo, resolved.From, resolved.To)
+	}
+
+	// The rendered report shows its times in the same zone
+	renderURL, _ := url.Parse(app.buildRenderURL("http://grafana:3000", resolved))
+	if tz := renderURL.Query().Get("tz"); tz != "UTC" {
+		t.Errorf("Expected the report to be rendered in UTC, got %q", tz)
+	}
+}
@@ -0,0 +1,45 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan starts a span with the plugin SDK's tracer, which exports to Grafana's configured tracing backend
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.DefaultTracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err (if any) on the span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectTraceHeaders propagates the trace context of ctx into outgoing request headers
+func injectTraceHeaders(ctx context.Context, req *http.Request) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
+
+// jobAttributes returns the span attributes identifying a job
+func jobAttributes(job Job) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("job.id", job.ID),
+		attribute.String("job.format", job.Format),
+		attribute.String("dashboard.uid", job.DashboardUID),
+	}
+	if job.PanelID != nil {
+		attrs = append(attrs, attribute.Int("panel.id", *job.PanelID))
+	}
+	return attrs
+}
@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestExecuteJobTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracing.InitDefaultTracer(provider.Tracer("test"))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceparent string
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte("fake png"))
+	}))
+	defer grafana.Close()
+
+	app := newTestApp(t)
+	app.config.GrafanaURL = grafana.URL
+
+	// SMTP is not configured, so the email span fails
+	app.executeJob(context.Background(), Job{ID: "daily", DashboardUID: "abc123", Format: "png"})
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	for _, name := range []string{"executeJob", "renderReport", "sendEmail"} {
+		if _, ok := spans[name]; !ok {
+			t.Fatalf("Expected %s span, got %v", name, spans)
+		}
+	}
+
+	root := spans["executeJob"]
+	if spans["renderReport"].Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Error("Expected renderReport to be a child of executeJob")
+	}
+	if spans["sendEmail"].Status().Code != codes.Error || root.Status().Code != codes.Error {
+		t.Error("Expected email failure to be recorded on the spans")
+	}
+
+	attrs := make(map[string]string)
+	for _, attr := range spans["renderReport"].Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs["job.id"] != "daily" || attrs["dashboard.uid"] != "abc123" || attrs["http.status_code"] != "200" {
+		t.Errorf("Unexpected renderReport attributes: %v", attrs)
+	}
+
+	if traceparent == "" {
+		t.Error("Expected trace context to be propagated to the render request")
+	}
+}